@@ -0,0 +1,67 @@
+// Command puzzlecheck validates the configured puzzle source and exits
+// non-zero if any puzzle has a problem, so it can run as a pre-merge check:
+//
+//	puzzlecheck -source dir -path puzzles/
+//
+// It exits 0 when everything is valid, 1 when problems were found and 2 when
+// the puzzles could not be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"references/internal/config"
+	"references/internal/game"
+)
+
+func main() {
+	cfg := config.Load()
+	source := flag.String("source", cfg.PuzzleSource, "puzzle source: static, sheets, csv, json, yaml or dir (default from PUZZLE_SOURCE)")
+	path := flag.String("path", cfg.PuzzlePath, "puzzle file or directory (default from PUZZLE_PATH)")
+	from := flag.String("from", time.Now().UTC().Format("2006-01-02"), "first day the calendar must cover")
+	ahead := flag.Int("ahead", 14, "number of days after -from the calendar must cover")
+	flag.Parse()
+
+	start, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -from date %q\n", *from)
+		os.Exit(2)
+	}
+
+	cfg.PuzzleSource = *source
+	cfg.PuzzlePath = *path
+	cfg.AnalyticsSinks = "none"
+
+	sheet, err := game.NewSheet(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "initialise sheets: %v\n", err)
+		os.Exit(2)
+	}
+	src, err := game.NewPuzzleSource(cfg, sheet)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "initialise puzzle source: %v\n", err)
+		os.Exit(2)
+	}
+	lister, ok := src.(game.PuzzleLister)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "puzzle source %T cannot be listed\n", src)
+		os.Exit(2)
+	}
+	puzzles, err := lister.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read puzzles: %v\n", err)
+		os.Exit(2)
+	}
+
+	problems := game.ValidateAll(puzzles, start, start.AddDate(0, 0, *ahead))
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	fmt.Printf("%d puzzles checked, %d problems\n", len(puzzles), len(problems))
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
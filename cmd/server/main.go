@@ -1,36 +1,107 @@
 package main
 
 import (
-	"context"
 	"log"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"references/internal/accounts"
 	"references/internal/config"
+	"references/internal/flags"
 	"references/internal/game"
 	"references/internal/handlers"
+	"references/internal/leagues"
+	"references/internal/lifecycle"
+	"references/internal/signing"
 )
 
 func main() {
 	cfg := config.Load()
 
-	g, err := game.NewGame(cfg)
+	sheet, err := game.NewSheet(cfg)
+	if err != nil {
+		log.Fatalf("initialise sheets: %v", err)
+	}
+	source, err := game.NewPuzzleSource(cfg, sheet)
+	if err != nil {
+		log.Fatalf("initialise puzzle source: %v", err)
+	}
+
+	fl, err := flags.Load(cfg.FlagsPath)
+	if err != nil {
+		log.Fatalf("load flags: %v", err)
+	}
+
+	g, err := game.NewGame(cfg, sheet, source, fl)
 	if err != nil {
 		log.Fatalf("initialise game: %v", err)
 	}
 
-	h := handlers.NewHandlers(g)
+	shutdownTimeout, err := time.ParseDuration(cfg.ShutdownTimeout)
+	if err != nil {
+		log.Fatalf("invalid SHUTDOWN_TIMEOUT %q: %v", cfg.ShutdownTimeout, err)
+	}
+
+	keys, err := signing.Load(cfg)
+	if err != nil {
+		log.Fatalf("load signing keys: %v", err)
+	}
+
+	accts, err := accounts.New(cfg, keys)
+	if err != nil {
+		log.Fatalf("initialise accounts: %v", err)
+	}
+
+	lg, err := leagues.New(cfg)
+	if err != nil {
+		log.Fatalf("initialise leagues: %v", err)
+	}
+
+	h := handlers.NewHandlers(g, accts, keys, lg)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", h.IndexHandler)
+	mux.HandleFunc("GET /archive", h.ArchiveHandler)
+	mux.HandleFunc("GET /play/{date}", h.PlayHandler)
 	mux.HandleFunc("/guess", h.GuessHandler)
 	mux.HandleFunc("/hint", h.HintHandler)
 	mux.HandleFunc("/stats", h.StatsHandler)
+	mux.HandleFunc("GET /leaderboard", h.LeaderboardHandler)
+	mux.HandleFunc("POST /leaderboard/name", h.LeaderboardNameHandler)
 	mux.HandleFunc("/success", h.SuccessHandler)
 	mux.HandleFunc("/maybe-tomorrow", h.MaybeTomorrowHandler)
+	mux.HandleFunc("/api/v1/puzzles/{gameId}", h.APIPuzzleHandler)
+	mux.HandleFunc("/api/v1/puzzles/{gameId}/guesses", h.APIGuessHandler)
+	mux.HandleFunc("/api/v1/puzzles/{gameId}/hints", h.APIHintHandler)
+	mux.HandleFunc("/api/v1/puzzles/{gameId}/result", h.APIResultHandler)
+	mux.HandleFunc("/api/v1/puzzles/{gameId}/stats", h.APIStatsHandler)
+	mux.HandleFunc("/api/v1/puzzles/{gameId}/leaderboard", h.APILeaderboardHandler)
+	mux.HandleFunc("/api/v1/players", h.APIPlayerHandler)
+	mux.HandleFunc("/api/v1/players/{playerId}/history", h.APIHistoryHandler)
+	mux.HandleFunc("/api/v1/players/{playerId}/name", h.APIPlayerNameHandler)
+	mux.HandleFunc("/api/v1/openapi.json", h.OpenAPIHandler)
+	mux.HandleFunc("/api/v1/", h.APINotFoundHandler)
+	mux.HandleFunc("/api/v1/leagues", h.APILeagueCreateHandler)
+	mux.HandleFunc("/api/v1/leagues/join", h.APILeagueJoinHandler)
+	mux.HandleFunc("/api/v1/leagues/{leagueId}/standings", h.APILeagueStandingsHandler)
+	mux.HandleFunc("GET /leagues", h.LeaguesHandler)
+	mux.HandleFunc("POST /leagues", h.LeagueCreateHandler)
+	mux.HandleFunc("POST /leagues/join", h.LeagueJoinHandler)
+	mux.HandleFunc("GET /leagues/{id}", h.LeagueHandler)
+	mux.HandleFunc("POST /leagues/{id}/leave", h.LeagueLeaveHandler)
+	mux.HandleFunc("GET /account", h.AccountHandler)
+	mux.HandleFunc("POST /account/login", h.AccountLoginHandler)
+	mux.HandleFunc("GET /account/verify", h.AccountVerifyHandler)
+	mux.HandleFunc("POST /account/logout", h.AccountLogoutHandler)
+	mux.HandleFunc("GET /debug/analytics", h.RequireAdmin(h.AnalyticsStatusHandler))
+	mux.HandleFunc("GET /admin", h.RequireAdmin(h.AdminHandler))
+	mux.HandleFunc("GET /admin/puzzles/new", h.RequireAdmin(h.AdminEditHandler))
+	mux.HandleFunc("GET /admin/puzzles/{id}", h.RequireAdmin(h.AdminEditHandler))
+	mux.HandleFunc("POST /admin/puzzles", h.RequireAdmin(h.AdminSaveHandler))
+	mux.HandleFunc("POST /admin/puzzles/{id}", h.RequireAdmin(h.AdminSaveHandler))
+	mux.HandleFunc("POST /admin/preview", h.RequireAdmin(h.AdminPreviewHandler))
+	mux.HandleFunc("GET /admin/flags", h.RequireAdmin(h.AdminFlagsHandler))
+	mux.HandleFunc("POST /admin/flags/reload", h.RequireAdmin(h.AdminReloadFlagsHandler))
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("web/static"))))
 
 	srv := &http.Server{
@@ -38,23 +109,16 @@ func main() {
 		Handler: mux,
 	}
 
-	errCh := make(chan error, 1)
-	go func() {
-		log.Printf("listening on %s (mode=%s)", srv.Addr, cfg.Mode)
-		errCh <- srv.ListenAndServe()
-	}()
+	lc := lifecycle.New(srv, shutdownTimeout)
+	lc.Go("scheduler", game.NewScheduler(g).Run)
+	lc.Go("stats", g.Stats.Run)
+	lc.Go("history", g.History.Run)
+	lc.Go("sessions", g.Sessions.Run)
+	lc.Go("flags", fl.Run)
+	lc.OnStop("analytics", sheet.StopAnalytics)
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	select {
-	case sig := <-sigCh:
-		log.Printf("shutdown signal: %v", sig)
-	case err := <-errCh:
+	log.Printf("starting (mode=%s)", cfg.Mode)
+	if err := lc.Run(); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	_ = srv.Shutdown(ctx)
 }
@@ -0,0 +1,197 @@
+// Package accounts lets players sign in with a link sent by email, so their
+// history follows them between devices. Accounts are optional: anonymous
+// players keep using the player ID in their signed player cookie.
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"references/internal/config"
+	"references/internal/signing"
+)
+
+const (
+	loginTokenTTL  = 15 * time.Minute
+	loginCooldown  = time.Minute
+	sessionTTL     = 180 * 24 * time.Hour
+	SessionCookie  = "references_account"
+	sessionPurpose = "account-session"
+	verifyPath     = "/account/verify"
+	loginTokenSize = 32
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email address")
+	ErrTooSoon      = errors.New("a sign-in link was sent recently")
+	ErrInvalidLink  = errors.New("sign-in link is invalid or has expired")
+)
+
+type loginRequest struct {
+	email    string
+	playerID string
+	expires  time.Time
+}
+
+type Accounts struct {
+	Store   *Store
+	mailer  Mailer
+	baseURL string
+	keys    *signing.Keys
+	secure  bool
+
+	mu       sync.Mutex
+	pending  map[string]loginRequest
+	lastSent map[string]time.Time
+}
+
+// New returns nil if no mailer is configured, which disables accounts.
+// Session cookies are signed with keys.
+func New(cfg config.Config, keys *signing.Keys) (*Accounts, error) {
+	mailer, err := NewMailer(cfg)
+	if err != nil || mailer == nil {
+		return nil, err
+	}
+	store, err := OpenStore(filepath.Join(cfg.DataDir, "accounts.json"))
+	if err != nil {
+		return nil, err
+	}
+	return &Accounts{
+		Store:    store,
+		mailer:   mailer,
+		baseURL:  strings.TrimSuffix(cfg.BaseGameURL, "/"),
+		keys:     keys,
+		secure:   cfg.Mode == config.ModeProd,
+		pending:  make(map[string]loginRequest),
+		lastSent: make(map[string]time.Time),
+	}, nil
+}
+
+// StartLogin emails a single-use sign-in link. playerID is the anonymous ID
+// the browser is playing as, which the account claims once the link is
+// followed.
+func (a *Accounts) StartLogin(ctx context.Context, email, playerID string) error {
+	email, ok := NormaliseEmail(email)
+	if !ok {
+		return ErrInvalidEmail
+	}
+	b := make([]byte, loginTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	a.mu.Lock()
+	if now.Sub(a.lastSent[email]) < loginCooldown {
+		a.mu.Unlock()
+		return ErrTooSoon
+	}
+	for t, req := range a.pending {
+		if now.After(req.expires) {
+			delete(a.pending, t)
+		}
+	}
+	a.pending[token] = loginRequest{email: email, playerID: playerID, expires: now.Add(loginTokenTTL)}
+	a.lastSent[email] = now
+	a.mu.Unlock()
+
+	link := a.baseURL + verifyPath + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Follow this link to sign in to References:\n\n%s\n\nThe link works once and expires in %d minutes. If you did not ask to sign in, ignore this email.", link, int(loginTokenTTL.Minutes()))
+	return a.mailer.Send(ctx, email, "Sign in to References", body)
+}
+
+// CompleteLogin redeems a sign-in token. merged is the anonymous player ID
+// to merge into an existing account, or empty.
+func (a *Accounts) CompleteLogin(token string) (acct Account, merged string, err error) {
+	a.mu.Lock()
+	req, ok := a.pending[token]
+	delete(a.pending, token)
+	a.mu.Unlock()
+	if !ok || time.Now().After(req.expires) {
+		return Account{}, "", ErrInvalidLink
+	}
+	acct, isMerge, err := a.Store.Claim(req.email, req.playerID)
+	if err != nil {
+		return Account{}, "", err
+	}
+	if isMerge {
+		merged = req.playerID
+	}
+	return acct, merged, nil
+}
+
+// SetSession signs the browser in as acct.
+func (a *Accounts) SetSession(w http.ResponseWriter, acct Account) {
+	expires := time.Now().Add(sessionTTL)
+	payload := acct.ID + "." + strconv.FormatInt(expires.Unix(), 10)
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    a.keys.Sign(sessionPurpose, payload),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (a *Accounts) ClearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// FromRequest returns the account the request is signed in as.
+func (a *Accounts) FromRequest(r *http.Request) (Account, bool) {
+	acct, _, ok := a.session(r)
+	return acct, ok
+}
+
+// RefreshSession re-signs a session cookie signed with a retired key, so
+// sessions move to the current key as players return.
+func (a *Accounts) RefreshSession(w http.ResponseWriter, r *http.Request) {
+	if acct, stale, ok := a.session(r); ok && stale {
+		a.SetSession(w, acct)
+	}
+}
+
+func (a *Accounts) session(r *http.Request) (acct Account, stale bool, ok bool) {
+	c, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return Account{}, false, false
+	}
+	payload, stale, ok := a.keys.Verify(sessionPurpose, c.Value)
+	if !ok {
+		return Account{}, false, false
+	}
+	id, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return Account{}, false, false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().After(time.Unix(unix, 0)) {
+		return Account{}, false, false
+	}
+	acct, err = a.Store.Get(id)
+	if err != nil {
+		return Account{}, false, false
+	}
+	return acct, stale, true
+}
@@ -0,0 +1,84 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"references/internal/config"
+)
+
+const (
+	MailerLog  = "log"
+	MailerSMTP = "smtp"
+	MailerNone = "none"
+)
+
+// Mailer delivers sign-in links.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// NewMailer returns the configured mailer, or nil if accounts are disabled.
+// MAILER defaults to writing messages to the log in local mode and to no
+// mailer in prod.
+func NewMailer(cfg config.Config) (Mailer, error) {
+	name := cfg.Mailer
+	if name == "" {
+		name = MailerLog
+		if cfg.Mode == config.ModeProd {
+			name = MailerNone
+		}
+	}
+	switch name {
+	case MailerNone:
+		return nil, nil
+	case MailerLog:
+		return LogMailer{}, nil
+	case MailerSMTP:
+		if cfg.SMTPAddr == "" || cfg.MailFrom == "" {
+			return nil, fmt.Errorf("MAILER=smtp requires SMTP_ADDR and MAIL_FROM")
+		}
+		return &SMTPMailer{Addr: cfg.SMTPAddr, From: cfg.MailFrom, User: cfg.SMTPUser, Password: cfg.SMTPPassword}, nil
+	}
+	return nil, fmt.Errorf("unknown mailer %q", name)
+}
+
+// LogMailer writes messages to the log instead of sending them, for local
+// development.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	log.Printf("mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	User     string
+	Password string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", to)
+	}
+	var auth smtp.Auth
+	if m.User != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP_ADDR %q: %w", m.Addr, err)
+		}
+		auth = smtp.PlainAuth("", m.User, m.Password, host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		m.From, to, subject, strings.ReplaceAll(body, "\n", "\r\n"))
+	if err := smtp.SendMail(m.Addr, auth, m.From, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownAccount = errors.New("unknown account")
+	ErrClaimed        = errors.New("player ID belongs to another account")
+)
+
+// Account is a signed-in player. PlayerID is the player ID the account plays
+// as on every device; Claimed lists the anonymous IDs merged into it.
+type Account struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	PlayerID  string    `json:"playerId"`
+	Claimed   []string  `json:"claimed,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Store keeps accounts in a JSON file, rewritten on every change.
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	accounts map[string]*Account
+}
+
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, accounts: make(map[string]*Account)}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read accounts: %w", err)
+	}
+	var list []*Account
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	for _, a := range list {
+		s.accounts[a.ID] = a
+	}
+	return s, nil
+}
+
+func (s *Store) Get(id string) (Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.accounts[id]
+	if !ok {
+		return Account{}, fmt.Errorf("%w: %s", ErrUnknownAccount, id)
+	}
+	return *a, nil
+}
+
+// Claim finds or creates the account for email and claims playerID for it.
+// It returns the account and whether playerID is merged into an existing
+// account, in which case its history should be moved to the account's
+// PlayerID. That is also true for an ID the account claimed before, since
+// the browser may have played as it again while signed out. A player ID
+// that belongs to another account is refused with ErrClaimed.
+func (s *Store) Claim(email, playerID string) (Account, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var acct *Account
+	for _, a := range s.accounts {
+		if a.Email == email {
+			acct = a
+			break
+		}
+	}
+	if playerID != "" {
+		for _, a := range s.accounts {
+			if a != acct && a.owns(playerID) {
+				return Account{}, false, ErrClaimed
+			}
+		}
+	}
+	if acct == nil {
+		id, err := randomID()
+		if err != nil {
+			return Account{}, false, err
+		}
+		acct = &Account{ID: id, Email: email, PlayerID: playerID, CreatedAt: time.Now()}
+		if playerID != "" {
+			acct.Claimed = []string{playerID}
+		}
+		s.accounts[id] = acct
+		return *acct, false, s.save()
+	}
+
+	if playerID == "" || playerID == acct.PlayerID {
+		return *acct, false, nil
+	}
+	for _, id := range acct.Claimed {
+		if id == playerID {
+			return *acct, true, nil
+		}
+	}
+	acct.Claimed = append(acct.Claimed, playerID)
+	if acct.PlayerID == "" {
+		acct.PlayerID = playerID
+		return *acct, false, s.save()
+	}
+	return *acct, true, s.save()
+}
+
+func (a *Account) owns(playerID string) bool {
+	if a.PlayerID == playerID {
+		return true
+	}
+	for _, id := range a.Claimed {
+		if id == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) save() error {
+	list := make([]*Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		list = append(list, a)
+	}
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NormaliseEmail lower-cases an address and checks it has the shape
+// local@domain.
+func NormaliseEmail(email string) (string, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 1 || at == len(email)-1 || strings.ContainsAny(email, " \r\n\t<>,") {
+		return "", false
+	}
+	return email, true
+}
@@ -10,12 +10,41 @@ const (
 )
 
 type Config struct {
-	Mode                Mode
-	Port                string
-	BaseGameURL         string
-	CredentialsJSONPath string
-	WordSheetID         string
-	AnalyticsSheetID    string
+	Mode                   Mode
+	Port                   string
+	BaseGameURL            string
+	CredentialsJSONPath    string
+	WordSheetID            string
+	AnalyticsSheetID       string
+	PuzzleTimezone         string
+	RolloverTime           string
+	PuzzleSource           string
+	PuzzlePath             string
+	PuzzleFallback         string
+	DataDir                string
+	AnalyticsSinks         string
+	AnalyticsJSONLPath     string
+	AnalyticsSQLitePath    string
+	AnalyticsSpoolDir      string
+	AnalyticsFlushInterval string
+	SheetsEndpoint         string
+	SheetsBatchSize        string
+	SheetsWritesPerMinute  string
+	SheetsMaxRetries       string
+	ShutdownTimeout        string
+	AdminUser              string
+	AdminPassword          string
+	NearMissDistance       string
+	NearMissLimit          string
+	FeedbackMode           string
+	FlagsPath              string
+	Mailer                 string
+	MailFrom               string
+	SMTPAddr               string
+	SMTPUser               string
+	SMTPPassword           string
+	SigningKeys            string
+	LeagueScoring          string
 }
 
 func Load() Config {
@@ -31,11 +60,40 @@ func Load() Config {
 	}
 
 	return Config{
-		Mode:                mode,
-		Port:                get("PORT", "8080"),
-		BaseGameURL:         get("BASE_GAME_URL", "http://localhost:8080"),
-		CredentialsJSONPath: get("GOOGLE_CREDS_JSON", ""),
-		WordSheetID:         get("WORD_SHEET_ID", ""),
-		AnalyticsSheetID:    get("ANALYTICS_SHEET_ID", ""),
+		Mode:                   mode,
+		Port:                   get("PORT", "8080"),
+		BaseGameURL:            get("BASE_GAME_URL", "http://localhost:8080"),
+		CredentialsJSONPath:    get("GOOGLE_CREDS_JSON", ""),
+		WordSheetID:            get("WORD_SHEET_ID", ""),
+		AnalyticsSheetID:       get("ANALYTICS_SHEET_ID", ""),
+		PuzzleTimezone:         get("PUZZLE_TZ", "UTC"),
+		RolloverTime:           get("ROLLOVER_TIME", "00:00"),
+		PuzzleSource:           get("PUZZLE_SOURCE", ""),
+		PuzzlePath:             get("PUZZLE_PATH", ""),
+		PuzzleFallback:         get("PUZZLE_FALLBACK", "sequential"),
+		DataDir:                get("DATA_DIR", "data"),
+		AnalyticsSinks:         get("ANALYTICS_SINKS", ""),
+		AnalyticsJSONLPath:     get("ANALYTICS_JSONL_PATH", ""),
+		AnalyticsSQLitePath:    get("ANALYTICS_SQLITE_PATH", ""),
+		AnalyticsSpoolDir:      get("ANALYTICS_SPOOL_DIR", ""),
+		AnalyticsFlushInterval: get("ANALYTICS_FLUSH_INTERVAL", "5s"),
+		SheetsEndpoint:         get("SHEETS_ENDPOINT", ""),
+		SheetsBatchSize:        get("SHEETS_BATCH_SIZE", "200"),
+		SheetsWritesPerMinute:  get("SHEETS_WRITES_PER_MINUTE", "60"),
+		SheetsMaxRetries:       get("SHEETS_MAX_RETRIES", "5"),
+		ShutdownTimeout:        get("SHUTDOWN_TIMEOUT", "8s"),
+		AdminUser:              get("ADMIN_USER", "admin"),
+		AdminPassword:          get("ADMIN_PASSWORD", ""),
+		NearMissDistance:       get("NEAR_MISS_DISTANCE", "1"),
+		NearMissLimit:          get("NEAR_MISS_LIMIT", "3"),
+		FeedbackMode:           get("FEEDBACK_MODE", "none"),
+		FlagsPath:              get("FLAGS_PATH", ""),
+		Mailer:                 get("MAILER", ""),
+		MailFrom:               get("MAIL_FROM", ""),
+		SMTPAddr:               get("SMTP_ADDR", ""),
+		SMTPUser:               get("SMTP_USER", ""),
+		SMTPPassword:           get("SMTP_PASSWORD", ""),
+		SigningKeys:            get("SIGNING_KEYS", ""),
+		LeagueScoring:          get("LEAGUE_SCORING", "solve=10,guess=2,hint=1,fail=0"),
 	}
 }
@@ -0,0 +1,276 @@
+// Package flags holds runtime feature flags. Values come from built-in
+// defaults, then an optional JSON file, then FLAG_* environment variables,
+// and the file is re-read while the server runs so flags change without a
+// redeploy.
+package flags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// PartialUnmasking uncovers correctly placed letters after a wrong guess
+	// on puzzles whose feedback mode is "none". It supports rollout.
+	PartialUnmasking = "partial_unmasking"
+	// SequentialDailyWord picks fallback puzzles in order by days since
+	// DailyWordStartDate rather than at random.
+	SequentialDailyWord = "sequential_daily_word"
+	DailyWordStartDate  = "daily_word_start_date"
+	// GameNumberEpoch is the date of game #1, and the first day the archive
+	// goes back to.
+	GameNumberEpoch = "game_number_epoch"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// reloadInterval is how often Run checks the file for changes.
+	reloadInterval = 30 * time.Second
+)
+
+type kind int
+
+const (
+	kindBool kind = iota
+	kindDate
+)
+
+type definition struct {
+	kind kind
+	def  string
+}
+
+var definitions = map[string]definition{
+	PartialUnmasking:    {kindBool, "false"},
+	SequentialDailyWord: {kindBool, "true"},
+	DailyWordStartDate:  {kindDate, "2025-04-10"},
+	GameNumberEpoch:     {kindDate, "2025-04-11"},
+}
+
+// Flag is the configured state of one flag. Rollout is the percentage of
+// players, 0-100, who see Value in per-player checks; everyone else sees
+// the default. Global checks ignore Rollout.
+type Flag struct {
+	Value   string `json:"value"`
+	Rollout int    `json:"rollout"`
+}
+
+// UnmarshalJSON accepts values of any JSON type, and also a bare value, so
+// the file can say "partial_unmasking": true instead of
+// {"value": true}.
+func (f *Flag) UnmarshalJSON(raw []byte) error {
+	var v struct {
+		Value   interface{} `json:"value"`
+		Rollout *int        `json:"rollout"`
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] != '{' {
+		if err := json.Unmarshal(trimmed, &v.Value); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(raw, &v); err != nil {
+		return err
+	}
+	*f = Flag{Value: fmt.Sprint(v.Value), Rollout: 100}
+	if v.Rollout != nil {
+		f.Rollout = *v.Rollout
+	}
+	return nil
+}
+
+type set map[string]Flag
+
+type Flags struct {
+	path    string
+	current atomic.Pointer[set]
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// Load reads flags from the file at path, which may be empty, and the
+// environment.
+func Load(path string) (*Flags, error) {
+	f := &Flags{path: path}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload re-reads the file and environment. On error the current flags are
+// kept.
+func (f *Flags) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := make(set, len(definitions))
+	for name, d := range definitions {
+		s[name] = Flag{Value: d.def, Rollout: 100}
+	}
+
+	var modTime time.Time
+	if f.path != "" {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			return fmt.Errorf("read flags: %w", err)
+		}
+		modTime = info.ModTime()
+		raw, err := os.ReadFile(f.path)
+		if err != nil {
+			return fmt.Errorf("read flags: %w", err)
+		}
+		var fromFile map[string]Flag
+		if err := json.Unmarshal(raw, &fromFile); err != nil {
+			return fmt.Errorf("decode %s: %w", f.path, err)
+		}
+		for name, flag := range fromFile {
+			if _, ok := definitions[name]; !ok {
+				log.Printf("flags: ignoring unknown flag %q in %s", name, f.path)
+				continue
+			}
+			s[name] = flag
+		}
+	}
+
+	for name := range definitions {
+		key := "FLAG_" + strings.ToUpper(name)
+		flag := s[name]
+		if v := os.Getenv(key); v != "" {
+			flag.Value = v
+		}
+		if v := os.Getenv(key + "_ROLLOUT"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s_ROLLOUT %q", key, v)
+			}
+			flag.Rollout = n
+		}
+		s[name] = flag
+	}
+
+	for name, flag := range s {
+		if err := validate(name, flag); err != nil {
+			return err
+		}
+	}
+
+	f.current.Store(&s)
+	f.modTime = modTime
+	return nil
+}
+
+func validate(name string, flag Flag) error {
+	if flag.Rollout < 0 || flag.Rollout > 100 {
+		return fmt.Errorf("flag %s: rollout must be between 0 and 100, got %d", name, flag.Rollout)
+	}
+	switch definitions[name].kind {
+	case kindBool:
+		if _, err := strconv.ParseBool(flag.Value); err != nil {
+			return fmt.Errorf("flag %s: %q is not true or false", name, flag.Value)
+		}
+	case kindDate:
+		if _, err := time.Parse(dateLayout, flag.Value); err != nil {
+			return fmt.Errorf("flag %s: %q is not a date like 2025-04-10", name, flag.Value)
+		}
+	}
+	return nil
+}
+
+// Run reloads the file whenever it changes until ctx is done.
+func (f *Flags) Run(ctx context.Context) {
+	if f.path == "" {
+		return
+	}
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(f.path)
+		if err != nil {
+			log.Printf("flags: %v", err)
+			continue
+		}
+		f.mu.Lock()
+		changed := !info.ModTime().Equal(f.modTime)
+		f.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if err := f.Reload(); err != nil {
+			log.Printf("flags: reload failed, keeping previous values: %v", err)
+			continue
+		}
+		log.Printf("flags: reloaded %s", f.path)
+	}
+}
+
+func (f *Flags) get(name string) Flag {
+	flag, ok := (*f.current.Load())[name]
+	if !ok {
+		panic("flags: unknown flag " + name)
+	}
+	return flag
+}
+
+// Bool returns a boolean flag's value for everyone.
+func (f *Flags) Bool(name string) bool {
+	v, _ := strconv.ParseBool(f.get(name).Value)
+	return v
+}
+
+// Date returns a date flag's value.
+func (f *Flags) Date(name string) time.Time {
+	t, _ := time.Parse(dateLayout, f.get(name).Value)
+	return t
+}
+
+// EnabledFor reports whether a boolean flag is on for playerID. A player is
+// in the rollout when a hash of the flag name and their ID falls within the
+// rollout percentage, so each player keeps the same answer as the rollout
+// grows.
+func (f *Flags) EnabledFor(name, playerID string) bool {
+	flag := f.get(name)
+	if bucket(name, playerID) >= flag.Rollout {
+		flag.Value = definitions[name].def
+	}
+	v, _ := strconv.ParseBool(flag.Value)
+	return v
+}
+
+func bucket(name, playerID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + playerID))
+	return int(h.Sum32() % 100)
+}
+
+// NamedFlag is a flag and its name, for listings.
+type NamedFlag struct {
+	Name string `json:"name"`
+	Flag
+}
+
+// All lists the current flags by name.
+func (f *Flags) All() []NamedFlag {
+	s := *f.current.Load()
+	out := make([]NamedFlag, 0, len(s))
+	for name, flag := range s {
+		out = append(out, NamedFlag{Name: name, Flag: flag})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
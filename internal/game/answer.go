@@ -0,0 +1,145 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"references/internal/config"
+)
+
+// MaskRune stands for a letter the player has not found yet.
+const MaskRune = '_'
+
+// Slot is one position of an answer: either a letter the player types or a
+// fixed separator such as a space, apostrophe or hyphen that is shown as
+// written.
+type Slot struct {
+	Rune   rune
+	Letter bool
+}
+
+func answerSlots(word string) []Slot {
+	var slots []Slot
+	for _, r := range word {
+		slots = append(slots, Slot{Rune: r, Letter: isAnswerLetter(r)})
+	}
+	return slots
+}
+
+func isAnswerLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// foldAnswer reduces s to the letters a player types: separators are
+// dropped, case is folded and diacritics are removed, so "Rock 'n' Roll"
+// and "rocknroll", or "Café" and "cafe", compare equal.
+func foldAnswer(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) || !isAnswerLetter(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// typeable reports whether a letter slot can be entered from a plain
+// keyboard, allowing for diacritics that foldAnswer strips.
+func typeable(r rune) bool {
+	folded := foldAnswer(string(r))
+	if folded == "" {
+		return false
+	}
+	for _, f := range folded {
+		if f > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// answerSeparators are the characters that may appear between words.
+const answerSeparators = " '’-.,&!?:"
+
+// NearMissPolicy decides when a wrong guess is close enough to tell the
+// player so without using up one of their guesses. Distance is the largest
+// edit distance that counts as close, and Limit is how many such free
+// guesses a player gets per puzzle so the hint cannot be used to search for
+// the answer.
+type NearMissPolicy struct {
+	Distance int
+	Limit    int
+}
+
+func parseNearMissPolicy(cfg config.Config) (NearMissPolicy, error) {
+	distance, err := strconv.Atoi(cfg.NearMissDistance)
+	if err != nil || distance < 0 {
+		return NearMissPolicy{}, fmt.Errorf("invalid NEAR_MISS_DISTANCE %q", cfg.NearMissDistance)
+	}
+	limit, err := strconv.Atoi(cfg.NearMissLimit)
+	if err != nil || limit < 0 {
+		return NearMissPolicy{}, fmt.Errorf("invalid NEAR_MISS_LIMIT %q", cfg.NearMissLimit)
+	}
+	return NearMissPolicy{Distance: distance, Limit: limit}, nil
+}
+
+// accepts reports whether a folded guess matches the answer or one of its
+// alternates.
+func (p *Puzzle) accepts(folded string) bool {
+	if folded == foldAnswer(p.Word) {
+		return true
+	}
+	for _, alt := range p.Alternates {
+		if folded == foldAnswer(alt) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNearMiss reports whether a folded guess is within the near-miss distance
+// of the answer or an alternate. Answers too short for the distance to mean
+// "a typo" never produce near misses.
+func (p *Puzzle) isNearMiss(folded string) bool {
+	d := p.NearMiss.Distance
+	if d == 0 || folded == "" {
+		return false
+	}
+	for _, target := range append([]string{p.Word}, p.Alternates...) {
+		t := foldAnswer(target)
+		if len([]rune(t)) <= 2*d {
+			continue
+		}
+		if editDistance(folded, t) <= d {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the Levenshtein distance between a and b in runes.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
@@ -0,0 +1,149 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+type FallbackPolicy string
+
+const (
+	// FallbackSequential cycles through undated puzzles (or every puzzle if
+	// all are dated) following the Rotation.
+	FallbackSequential FallbackPolicy = "sequential"
+	// FallbackLatest reissues the most recent puzzle dated before the day.
+	FallbackLatest FallbackPolicy = "latest"
+	// FallbackNone fails the lookup with ErrNoPuzzle, keeping the previous
+	// puzzle live.
+	FallbackNone FallbackPolicy = "none"
+)
+
+func ParseFallbackPolicy(s string) (FallbackPolicy, error) {
+	switch p := FallbackPolicy(s); p {
+	case FallbackSequential, FallbackLatest, FallbackNone:
+		return p, nil
+	case "":
+		return FallbackSequential, nil
+	}
+	return "", fmt.Errorf("unknown puzzle fallback policy %q", s)
+}
+
+type Calendar struct {
+	byDate     map[string]*WordData
+	dates      []time.Time
+	undated    []*WordData
+	all        []*WordData
+	byAnswer   map[string]*WordData
+	duplicates map[string][]*WordData
+}
+
+func NewCalendar(puzzles []*WordData) *Calendar {
+	c := &Calendar{
+		byDate:     make(map[string]*WordData),
+		all:        puzzles,
+		byAnswer:   make(map[string]*WordData),
+		duplicates: make(map[string][]*WordData),
+	}
+	for _, p := range puzzles {
+		if key := foldAnswer(p.Answer); c.byAnswer[key] == nil {
+			c.byAnswer[key] = p
+		}
+		if p.PublishDate.IsZero() {
+			c.undated = append(c.undated, p)
+			continue
+		}
+		key := p.PublishDate.Format(gameIDLayout)
+		if first, ok := c.byDate[key]; ok {
+			if len(c.duplicates[key]) == 0 {
+				c.duplicates[key] = []*WordData{first}
+			}
+			c.duplicates[key] = append(c.duplicates[key], p)
+			continue
+		}
+		c.byDate[key] = p
+		c.dates = append(c.dates, p.PublishDate)
+	}
+	sort.Slice(c.dates, func(i, j int) bool { return c.dates[i].Before(c.dates[j]) })
+	return c
+}
+
+// Lookup returns the puzzle published on date. When a date is duplicated the
+// first puzzle read from the source wins.
+func (c *Calendar) Lookup(date time.Time) (*WordData, bool) {
+	p, ok := c.byDate[date.Format(gameIDLayout)]
+	return p, ok
+}
+
+// ByAnswer returns the puzzle with answer, compared as guesses are.
+func (c *Calendar) ByAnswer(answer string) (*WordData, bool) {
+	p, ok := c.byAnswer[foldAnswer(answer)]
+	return p, ok
+}
+
+// Rotation decides which puzzle FallbackSequential picks: in order by days
+// since Start, or at random. Random picks are seeded by the date, so every
+// replica and restart picks the same puzzle for a day.
+type Rotation struct {
+	Sequential bool
+	Start      time.Time
+}
+
+func (c *Calendar) Resolve(date time.Time, policy FallbackPolicy, rot Rotation) (*WordData, error) {
+	if p, ok := c.Lookup(date); ok {
+		return p, nil
+	}
+
+	switch policy {
+	case FallbackSequential:
+		pool := c.undated
+		if len(pool) == 0 {
+			pool = c.all
+		}
+		if len(pool) == 0 {
+			break
+		}
+		return pool[rot.index(len(pool), date)], nil
+	case FallbackLatest:
+		for i := len(c.dates) - 1; i >= 0; i-- {
+			if c.dates[i].Before(date) {
+				return c.byDate[c.dates[i].Format(gameIDLayout)], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w for %s", ErrNoPuzzle, date.Format(gameIDLayout))
+}
+
+// Duplicates returns every publish date claimed by more than one puzzle.
+func (c *Calendar) Duplicates() map[string][]*WordData { return c.duplicates }
+
+// Gaps returns the days in [from, to] that have no dated puzzle.
+func (c *Calendar) Gaps(from, to time.Time) []time.Time {
+	var gaps []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if _, ok := c.Lookup(d); !ok {
+			gaps = append(gaps, d)
+		}
+	}
+	return gaps
+}
+
+// LastDate returns the latest publish date in the calendar.
+func (c *Calendar) LastDate() (time.Time, bool) {
+	if len(c.dates) == 0 {
+		return time.Time{}, false
+	}
+	return c.dates[len(c.dates)-1], true
+}
+
+func (rot Rotation) index(n int, date time.Time) int {
+	if rot.Sequential {
+		day := date.Truncate(24 * time.Hour)
+		start := rot.Start.Truncate(24 * time.Hour)
+		days := int(day.Sub(start).Hours() / 24)
+		return (days%n + n) % n
+	}
+	y, m, d := date.Date()
+	return rand.New(rand.NewSource(int64(y*10000 + int(m)*100 + d))).Intn(n)
+}
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"references/internal/flags"
+)
+
+// FeedbackMode decides what a wrong guess tells the player.
+type FeedbackMode string
+
+const (
+	// FeedbackNone only says the guess was wrong.
+	FeedbackNone FeedbackMode = "none"
+	// FeedbackReveal uncovers letters guessed in the right position.
+	FeedbackReveal FeedbackMode = "reveal"
+	// FeedbackLetters marks every guessed letter as correct, present
+	// elsewhere in the answer, or absent, and uncovers the correct ones.
+	FeedbackLetters FeedbackMode = "letters"
+)
+
+func ParseFeedbackMode(s string) (FeedbackMode, error) {
+	switch mode := FeedbackMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case FeedbackNone, FeedbackReveal, FeedbackLetters:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid feedback mode %q (want none, reveal or letters)", s)
+}
+
+// feedbackFor is the puzzle's feedback mode for one player: puzzles without
+// feedback reveal matching letters to players in the partial unmasking
+// rollout.
+func (p *Puzzle) feedbackFor(playerID string) FeedbackMode {
+	if p.Feedback == FeedbackNone && p.flags != nil && p.flags.EnabledFor(flags.PartialUnmasking, playerID) {
+		return FeedbackReveal
+	}
+	return p.Feedback
+}
+
+// LetterResult is the feedback for one letter of a guess.
+type LetterResult string
+
+const (
+	LetterCorrect LetterResult = "correct"
+	LetterPresent LetterResult = "present"
+	LetterAbsent  LetterResult = "absent"
+)
+
+// scoreGuess compares a folded guess with a folded answer letter by letter.
+// A repeated letter is only marked present as many times as the answer has
+// it spare after exact matches. Letters past the end of the answer are
+// ignored.
+func scoreGuess(answer, guess string) []LetterResult {
+	ar, gr := []rune(answer), []rune(guess)
+	if len(gr) > len(ar) {
+		gr = gr[:len(ar)]
+	}
+	results := make([]LetterResult, len(gr))
+	spare := make(map[rune]int)
+	for i, r := range ar {
+		if i < len(gr) && gr[i] == r {
+			results[i] = LetterCorrect
+		} else {
+			spare[r]++
+		}
+	}
+	for i, r := range gr {
+		if results[i] != "" {
+			continue
+		}
+		if spare[r] > 0 {
+			spare[r]--
+			results[i] = LetterPresent
+		} else {
+			results[i] = LetterAbsent
+		}
+	}
+	return results
+}
+
+var feedbackSquares = map[LetterResult]string{
+	LetterCorrect: "🟩",
+	LetterPresent: "🟨",
+	LetterAbsent:  "⬛",
+}
+
+// FeedbackGrid renders letter feedback as rows of coloured squares for the
+// share text, one row per guess.
+func FeedbackGrid(rows [][]LetterResult) string {
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		var b strings.Builder
+		for _, res := range row {
+			b.WriteString(feedbackSquares[res])
+		}
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
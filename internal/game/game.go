@@ -1,148 +1,539 @@
 package game
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"log"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
 	"references/internal/config"
+	"references/internal/flags"
 )
 
 const (
-	EnablePartialUnmasking = false
+	gameIDLayout = "2006-01-02"
 
-	UseSequentialDailyWord = true
+	archiveCacheSize = 64
 
-	DailyWordStartDateString = "2025-04-10"
+	MaxGuesses = 4
 )
 
 var (
-	dailyWordStartDate time.Time
+	ErrAlreadySolved = errors.New("puzzle already solved")
+	ErrNoGuessesLeft = errors.New("no guesses left")
+	ErrGameOver      = errors.New("game is over")
+
+	ErrInvalidCategory = errors.New("invalid category")
+	ErrUnknownGame     = errors.New("unknown game")
+	ErrUnknownPlayer   = errors.New("unknown player")
+
+	// ErrNoPuzzle means the calendar has nothing for a day under the
+	// configured fallback policy.
+	ErrNoPuzzle = errors.New("no puzzle scheduled")
 )
 
-func init() {
-	var err error
-	dailyWordStartDate, err = time.Parse("2006-01-02", DailyWordStartDateString)
-	if err != nil {
-		panic(fmt.Sprintf("invalid DailyWordStartDateString: %v", err))
-	}
+type Puzzle struct {
+	GameID string
+	// StoreID is the ID of the stored puzzle this was built from.
+	StoreID        string
+	Date           time.Time
+	Word           string
+	Hints          map[string]string
+	Categories     map[string]string
+	CategoryEmojis map[string]string
+	CategoryOrder  []string
+	Slots          []Slot
+	Alternates     []string
+	NearMiss       NearMissPolicy
+	Feedback       FeedbackMode
+	// Missing marks the placeholder that is live when no puzzle is
+	// scheduled for the day and there is no earlier puzzle to keep.
+	Missing bool
+	flags   *flags.Flags
+}
+
+type GuessResult struct {
+	Correct bool
+	// Close is set for a wrong guess within the near-miss distance that was
+	// not counted against the player.
+	Close             bool
+	RevealedPositions []int
+	// Letters is set for wrong guesses in FeedbackLetters mode.
+	Letters          []LetterResult
+	RemainingGuesses int
 }
 
 type Game struct {
-	Cfg               config.Config
-	Word              string
-	RevealedPositions map[int]bool
-	Hints             map[string]string
-	Categories        map[string]string
-	CategoryEmojis    map[string]string
-	CategoryOrder     []string
-	Sheet             *Sheet
+	Cfg      config.Config
+	Sheet    *Sheet
+	Source   PuzzleSource
+	Sessions *SessionStore
+	Stats    *StatsAggregator
+	History  *HistoryStore
+	Names    *NameStore
+	Flags    *flags.Flags
+	fallback FallbackPolicy
+	nearMiss NearMissPolicy
+	feedback FeedbackMode
+	location *time.Location
+	rollover time.Time
+	current  atomic.Pointer[Puzzle]
+	calendar atomic.Pointer[Calendar]
+	issued   *IssuedLog
+
+	archiveMu sync.Mutex
+	archive   map[string]*Puzzle
+}
+
+type ArchiveEntry struct {
+	GameID     string
+	GameNumber int
+	Date       time.Time
 }
 
-func NewGame(cfg config.Config) (*Game, error) {
-	sheet, err := NewSheet(cfg)
+func NewGame(cfg config.Config, sheet *Sheet, source PuzzleSource, fl *flags.Flags) (*Game, error) {
+	loc, err := time.LoadLocation(cfg.PuzzleTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid puzzle timezone %q: %w", cfg.PuzzleTimezone, err)
+	}
+	rollover, err := parseRolloverTime(cfg.RolloverTime)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err := ParseFallbackPolicy(cfg.PuzzleFallback)
+	if err != nil {
+		return nil, err
+	}
+	nearMiss, err := parseNearMissPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	feedback, err := ParseFeedbackMode(cfg.FeedbackMode)
+	if err != nil {
+		return nil, fmt.Errorf("FEEDBACK_MODE: %w", err)
+	}
+
+	stats, err := NewStatsAggregator(filepath.Join(cfg.DataDir, "stats.json"))
+	if err != nil {
+		return nil, err
+	}
+	history, err := NewHistoryStore(filepath.Join(cfg.DataDir, "history.json"))
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := NewSessionStore(filepath.Join(cfg.DataDir, "sessions.json"), history)
+	if err != nil {
+		return nil, err
+	}
+	issued, err := NewIssuedLog(filepath.Join(cfg.DataDir, "issued.json"))
+	if err != nil {
+		return nil, err
+	}
+	names, err := NewNameStore(filepath.Join(cfg.DataDir, "names.json"))
 	if err != nil {
 		return nil, err
 	}
 
 	g := &Game{
-		Cfg:               cfg,
-		Sheet:             sheet,
-		RevealedPositions: make(map[int]bool),
-		Hints:             make(map[string]string),
-		Categories:        make(map[string]string),
-		CategoryEmojis:    make(map[string]string),
+		Cfg:      cfg,
+		Sheet:    sheet,
+		Source:   source,
+		Sessions: sessions,
+		Stats:    stats,
+		History:  history,
+		Names:    names,
+		Flags:    fl,
+		fallback: fallback,
+		nearMiss: nearMiss,
+		feedback: feedback,
+		location: loc,
+		rollover: rollover,
+		issued:   issued,
+		archive:  make(map[string]*Puzzle),
 	}
 
-	if err := g.loadDailyWord(); err != nil {
+	if err := g.Rotate(time.Now()); err != nil && !errors.Is(err, ErrNoPuzzle) {
 		return nil, err
 	}
 	return g, nil
 }
 
-func (g *Game) loadDailyWord() error {
-	data, err := g.Sheet.GetDailyWord()
+// parseRolloverTime parses the wall clock time of day puzzles change at;
+// only its hour and minute are used.
+func parseRolloverTime(s string) (time.Time, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid rollover time %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// rolloverOn returns the moment the puzzle for the day of date goes live.
+// It is built from the wall clock, so it stays at the same local time on
+// days when daylight saving starts or ends.
+func (g *Game) rolloverOn(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), g.rollover.Hour(), g.rollover.Minute(), 0, 0, g.location)
+}
+
+// GameDate returns the puzzle day that is live at t, as midnight UTC.
+func (g *Game) GameDate(t time.Time) time.Time {
+	local := t.In(g.location)
+	if local.Before(g.rolloverOn(local)) {
+		local = local.AddDate(0, 0, -1)
+	}
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (g *Game) NextRollover(t time.Time) time.Time {
+	return g.rolloverOn(g.GameDate(t).AddDate(0, 0, 1))
+}
+
+// Current returns the live puzzle. Callers should fetch it once per request
+// so a rollover mid-request cannot mix two puzzles.
+func (g *Game) Current() *Puzzle { return g.current.Load() }
+
+// Rotate loads the puzzle for the game day at now and swaps it in if it
+// differs from the live one or no puzzle is live.
+func (g *Game) Rotate(now time.Time) error {
+	date := g.GameDate(now)
+	if cur := g.current.Load(); cur != nil && cur.Date.Equal(date) && !cur.Missing {
+		return nil
+	}
+	return g.swap(date)
+}
+
+// Reload re-reads the live puzzle from the source after it has been edited.
+// A day with no dated puzzle keeps the puzzle it went live with.
+func (g *Game) Reload() error {
+	return g.swap(g.GameDate(time.Now()))
+}
+
+// PuzzleSaved applies an edit to the puzzle stored as id. Today's puzzle is
+// reloaded only when pf is scheduled for today, is the live puzzle itself,
+// which stays live under its edited answer, or may fill a day with no
+// puzzle; any other edit just refreshes the calendar used for archive games.
+func (g *Game) PuzzleSaved(id string, pf PuzzleFile) error {
+	cur := g.Current()
+	if id != "" && id == cur.StoreID {
+		if err := g.issued.Record(cur.GameID, pf.Answer); err != nil {
+			return fmt.Errorf("record edited puzzle %s: %w", cur.GameID, err)
+		}
+		return g.Reload()
+	}
+	if pf.Date == cur.GameID || cur.Missing {
+		return g.Reload()
+	}
+	cal, err := g.Calendar()
 	if err != nil {
 		return err
 	}
-	g.Word = data.Answer
-	g.Hints = data.Hints
-	g.Categories = data.Categories
-	g.CategoryEmojis = data.CategoryEmojis
-	g.CategoryOrder = data.CategoryOrder
-	g.RevealedPositions = make(map[int]bool)
+	g.calendar.Store(cal)
+	g.resetArchive()
 	return nil
 }
 
-func (g *Game) CheckGuess(guess string) (bool, []int) {
-	normalisedGuess := strings.ToLower(strings.TrimSpace(guess))
-	normalisedWord := strings.ToLower(g.Word)
-	if normalisedGuess == normalisedWord {
-		for i := range []rune(g.Word) {
-			g.RevealedPositions[i] = true
+// swap reads the calendar from the source and makes the puzzle for date
+// live. The calendar is kept for archive lookups until the next swap. If
+// date has no puzzle the previous one stays live, or, when there is none, a
+// Missing placeholder for date; ErrNoPuzzle is still returned so the
+// scheduler keeps retrying.
+func (g *Game) swap(date time.Time) error {
+	cal, err := g.Calendar()
+	if err != nil {
+		return err
+	}
+	p, err := g.puzzleOn(cal, date)
+	if errors.Is(err, ErrNoPuzzle) {
+		if cur := g.current.Load(); cur == nil || cur.Missing {
+			g.calendar.Store(cal)
+			g.current.Store(&Puzzle{GameID: date.Format(gameIDLayout), Date: date, Missing: true, flags: g.Flags})
+			g.resetArchive()
 		}
-		return true, nil
+		return err
+	}
+	if err != nil {
+		return err
 	}
+	g.calendar.Store(cal)
+	g.current.Store(p)
+	if err := g.issued.Record(p.GameID, p.Word); err != nil {
+		log.Printf("record issued puzzle %s: %v", p.GameID, err)
+	}
+	g.resetArchive()
+	return nil
+}
 
-	var revealed []int
-	if EnablePartialUnmasking {
-		wr, gr := []rune(normalisedWord), []rune(normalisedGuess)
-		max := len(wr)
-		if len(gr) < max {
-			max = len(gr)
+func (g *Game) resetArchive() {
+	g.archiveMu.Lock()
+	g.archive = make(map[string]*Puzzle)
+	g.archiveMu.Unlock()
+}
+
+const calendarLookahead = 14
+
+// Calendar reads every puzzle from the source and logs scheduling problems
+// editors should fix: duplicated dates and gaps in the coming days.
+func (g *Game) Calendar() (*Calendar, error) {
+	puzzles, err := g.Source.Puzzles()
+	if err != nil {
+		return nil, err
+	}
+	cal := NewCalendar(puzzles)
+	for date, dups := range cal.Duplicates() {
+		log.Printf("calendar: %d puzzles scheduled for %s, using %q", len(dups), date, dups[0].Answer)
+	}
+	if _, dated := cal.LastDate(); dated {
+		today := g.GameDate(time.Now())
+		for _, gap := range cal.Gaps(today, today.AddDate(0, 0, calendarLookahead)) {
+			log.Printf("calendar: no puzzle scheduled for %s (fallback=%s)", gap.Format(gameIDLayout), g.fallback)
 		}
-		for i := 0; i < max; i++ {
-			if gr[i] == wr[i] && !g.RevealedPositions[i] {
-				g.RevealedPositions[i] = true
-				revealed = append(revealed, i)
+	}
+	return cal, nil
+}
+
+// puzzleOn returns the puzzle for date from cal: the puzzle dated for that
+// day, or else the answer the day was first served with, as long as that
+// puzzle is still in the source.
+func (g *Game) puzzleOn(cal *Calendar, date time.Time) (*Puzzle, error) {
+	if data, ok := cal.Lookup(date); ok {
+		return g.newPuzzle(date, data), nil
+	}
+	if answer, ok := g.issued.Answer(date.Format(gameIDLayout)); ok {
+		if data, ok := cal.ByAnswer(answer); ok {
+			return g.newPuzzle(date, data), nil
+		}
+	}
+	data, err := cal.Resolve(date, g.fallback, g.rotation())
+	if err != nil {
+		return nil, err
+	}
+	return g.newPuzzle(date, data), nil
+}
+
+// PreviewPuzzle builds the puzzle a draft would become, dated on its publish
+// date or today.
+func (g *Game) PreviewPuzzle(pf PuzzleFile) (*Puzzle, error) {
+	data, err := pf.wordData()
+	if err != nil {
+		return nil, err
+	}
+	date := data.PublishDate
+	if date.IsZero() {
+		date = g.GameDate(time.Now())
+	}
+	return g.newPuzzle(date, data), nil
+}
+
+func (g *Game) newPuzzle(date time.Time, data *WordData) *Puzzle {
+	feedback := data.Feedback
+	if feedback == "" {
+		feedback = g.feedback
+	}
+	return &Puzzle{
+		GameID:         date.Format(gameIDLayout),
+		StoreID:        data.StoreID,
+		Date:           date,
+		Word:           data.Answer,
+		Hints:          data.Hints,
+		Categories:     data.Categories,
+		CategoryEmojis: data.CategoryEmojis,
+		CategoryOrder:  data.CategoryOrder,
+		Slots:          answerSlots(data.Answer),
+		Alternates:     data.Alternates,
+		NearMiss:       g.nearMiss,
+		Feedback:       feedback,
+		flags:          g.Flags,
+	}
+}
+
+func (p *Puzzle) CheckGuess(s *Session, guess string) (*GuessResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastSeen = time.Now()
+	if s.Solved {
+		return nil, ErrAlreadySolved
+	}
+	if s.Guesses >= MaxGuesses {
+		return nil, ErrNoGuessesLeft
+	}
+	folded := foldAnswer(guess)
+	if p.accepts(folded) {
+		s.Guesses++
+		for i, slot := range p.Slots {
+			if slot.Letter {
+				s.RevealedPositions[i] = true
 			}
 		}
+		s.Solved = true
+		if p.Feedback == FeedbackLetters {
+			// An alternate answer can differ in length, so the row follows
+			// the answer's boxes rather than the guess.
+			row := make([]LetterResult, p.LetterCount())
+			for i := range row {
+				row[i] = LetterCorrect
+			}
+			s.Feedback = append(s.Feedback, row)
+		}
+		return &GuessResult{Correct: true, RemainingGuesses: MaxGuesses - s.Guesses}, nil
 	}
-	return false, revealed
+	if s.NearMisses < p.NearMiss.Limit && p.isNearMiss(folded) {
+		s.NearMisses++
+		return &GuessResult{Close: true, RemainingGuesses: MaxGuesses - s.Guesses}, nil
+	}
+	s.Guesses++
+
+	result := &GuessResult{RemainingGuesses: MaxGuesses - s.Guesses}
+	feedback := p.feedbackFor(s.PlayerID)
+	if feedback == FeedbackNone {
+		return result, nil
+	}
+	if feedback == FeedbackLetters {
+		result.Letters = scoreGuess(foldAnswer(p.Word), folded)
+		s.Feedback = append(s.Feedback, result.Letters)
+	}
+	gr := []rune(folded)
+	letter := 0
+	for i, slot := range p.Slots {
+		if !slot.Letter {
+			continue
+		}
+		if letter >= len(gr) {
+			break
+		}
+		if foldAnswer(string(slot.Rune)) == string(gr[letter]) && !s.RevealedPositions[i] {
+			s.RevealedPositions[i] = true
+			result.RevealedPositions = append(result.RevealedPositions, i)
+		}
+		letter++
+	}
+	return result, nil
 }
 
-func (g *Game) GetMaskedWord() string {
-	if len(g.RevealedPositions) == 0 {
-		masked := make([]rune, utf8.RuneCountInString(g.Word))
-		for i := range masked {
-			masked[i] = '_'
+// GetMaskedWord hides every letter and keeps separators as written.
+func (p *Puzzle) GetMaskedWord() string {
+	masked := make([]rune, len(p.Slots))
+	for i, slot := range p.Slots {
+		masked[i] = slot.Rune
+		if slot.Letter {
+			masked[i] = MaskRune
 		}
-		return string(masked)
 	}
-	return g.GetPartiallyRevealedWord()
+	return string(masked)
 }
 
-func (g *Game) GetPartiallyRevealedWord() string {
-	wr := []rune(g.Word)
-	out := make([]rune, len(wr))
-	for i := range out {
-		out[i] = '_'
+// LetterCount is the number of letters a player has to type.
+func (p *Puzzle) LetterCount() int {
+	n := 0
+	for _, slot := range p.Slots {
+		if slot.Letter {
+			n++
+		}
 	}
-	for i := range g.RevealedPositions {
-		out[i] = wr[i]
+	return n
+}
+
+func (p *Puzzle) GetPartiallyRevealedWord(s *Session) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []rune(p.GetMaskedWord())
+	for i := range s.RevealedPositions {
+		if i < len(p.Slots) {
+			out[i] = p.Slots[i].Rune
+		}
 	}
 	return string(out)
 }
 
-func (g *Game) GetHint(cat string) (string, error) {
-	if h, ok := g.Hints[cat]; ok {
+func (p *Puzzle) GetHint(cat string) (string, error) {
+	if h, ok := p.Hints[cat]; ok {
 		return h, nil
 	}
-	return "", fmt.Errorf("invalid category: %s", cat)
+	return "", fmt.Errorf("%w: %s", ErrInvalidCategory, cat)
 }
-func (g *Game) GetEmoji(cat string) (string, error) {
-	if e, ok := g.CategoryEmojis[cat]; ok {
+func (p *Puzzle) GetEmoji(cat string) (string, error) {
+	if e, ok := p.CategoryEmojis[cat]; ok {
 		return e, nil
 	}
 	return "", fmt.Errorf("no emoji for category: %s", cat)
 }
-func (g *Game) GetCategories() []string { return g.CategoryOrder }
-func (g *Game) GetAllCategoryEmojis() map[string]string {
-	copy := make(map[string]string, len(g.CategoryEmojis))
-	for k, v := range g.CategoryEmojis {
+func (p *Puzzle) GetCategories() []string { return p.CategoryOrder }
+func (p *Puzzle) GetAllCategoryEmojis() map[string]string {
+	copy := make(map[string]string, len(p.CategoryEmojis))
+	for k, v := range p.CategoryEmojis {
 		copy[k] = v
 	}
 	return copy
 }
-func (g *Game) GetDailyGameID() string { return time.Now().UTC().Format("2006-01-02") }
+func (g *Game) LogEvent(event Event) {
+	archive := event.GameID != g.Current().GameID
+	g.Stats.Record(event, archive)
+	g.History.Record(event, archive)
+	g.Sheet.LogEvent(event)
+}
+
+func (g *Game) GetDailyGameID() string { return g.Current().GameID }
+
+// Puzzle returns the live puzzle or a past day's puzzle by game ID. Future
+// days and days before the first game are not playable.
+func (g *Game) Puzzle(gameID string) (*Puzzle, bool) {
+	cur := g.Current()
+	if cur.GameID == gameID {
+		return cur, !cur.Missing
+	}
+	date, err := time.Parse(gameIDLayout, gameID)
+	if err != nil || !date.Before(cur.Date) || date.Before(g.epoch()) {
+		return nil, false
+	}
+
+	g.archiveMu.Lock()
+	p, ok := g.archive[gameID]
+	g.archiveMu.Unlock()
+	if ok {
+		return p, true
+	}
+
+	p, err = g.puzzleOn(g.calendar.Load(), date)
+	if err != nil {
+		log.Printf("load archived puzzle %s: %v", gameID, err)
+		return nil, false
+	}
+	if err := g.issued.Record(gameID, p.Word); err != nil {
+		log.Printf("record issued puzzle %s: %v", gameID, err)
+	}
+	g.archiveMu.Lock()
+	if len(g.archive) >= archiveCacheSize {
+		g.archive = make(map[string]*Puzzle)
+	}
+	g.archive[gameID] = p
+	g.archiveMu.Unlock()
+	return p, true
+}
+
+// Archive lists every past game, newest first.
+func (g *Game) Archive() []ArchiveEntry {
+	var entries []ArchiveEntry
+	for d := g.Current().Date.AddDate(0, 0, -1); !d.Before(g.epoch()); d = d.AddDate(0, 0, -1) {
+		entries = append(entries, ArchiveEntry{
+			GameID:     d.Format(gameIDLayout),
+			GameNumber: g.GameNumber(d),
+			Date:       d,
+		})
+	}
+	return entries
+}
+
+func (g *Game) epoch() time.Time { return g.Flags.Date(flags.GameNumberEpoch) }
+
+func (g *Game) GameNumber(date time.Time) int {
+	return int(date.Sub(g.epoch()).Hours()/24) + 1
+}
+
+func (g *Game) rotation() Rotation {
+	return Rotation{
+		Sequential: g.Flags.Bool(flags.SequentialDailyWord),
+		Start:      g.Flags.Date(flags.DailyWordStartDate),
+	}
+}
@@ -0,0 +1,276 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const historySnapshotInterval = 30 * time.Second
+
+// historyGame is one player's result for one game.
+type historyGame struct {
+	Guesses    int       `json:"guesses"`
+	Hints      int       `json:"hints"`
+	Solved     bool      `json:"solved"`
+	Finished   bool      `json:"finished"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+	// Archive is set for games played after their day, which do not count
+	// towards streaks.
+	Archive bool `json:"archive,omitempty"`
+}
+
+// HistoryStore keeps every player's results across games, keyed by player
+// ID, so streaks survive a cleared browser. Unlike StatsAggregator it is
+// never pruned.
+type HistoryStore struct {
+	mu      sync.Mutex
+	players map[string]map[string]*historyGame
+	path    string
+	dirty   bool
+}
+
+type PlayerHistory struct {
+	Played        int     `json:"played"`
+	Won           int     `json:"won"`
+	WinPercentage float64 `json:"winPercentage"`
+	CurrentStreak int     `json:"currentStreak"`
+	MaxStreak     int     `json:"maxStreak"`
+	// GuessDistribution counts wins by guesses used, index 0 for one guess.
+	GuessDistribution []int  `json:"guessDistribution"`
+	LastPlayed        string `json:"lastPlayed,omitempty"`
+}
+
+// GameResult is how a player finished one game.
+type GameResult struct {
+	Guesses int
+	Hints   int
+	Solved  bool
+}
+
+func NewHistoryStore(path string) (*HistoryStore, error) {
+	hs := &HistoryStore{players: make(map[string]map[string]*historyGame), path: path}
+	if path == "" {
+		return hs, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return hs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history snapshot: %w", err)
+	}
+	if err := json.Unmarshal(raw, &hs.players); err != nil {
+		return nil, fmt.Errorf("decode history snapshot: %w", err)
+	}
+	return hs, nil
+}
+
+// Record applies a guess or hint event. archive marks events for a game
+// other than the live one.
+func (hs *HistoryStore) Record(e Event, archive bool) {
+	if e.PlayerID == "" || (e.EventType != "guess" && e.EventType != "hint") {
+		return
+	}
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	games, ok := hs.players[e.PlayerID]
+	if !ok {
+		games = make(map[string]*historyGame)
+		hs.players[e.PlayerID] = games
+	}
+	g, ok := games[e.GameID]
+	if !ok {
+		g = &historyGame{Archive: archive}
+		games[e.GameID] = g
+	}
+	if g.Finished {
+		return
+	}
+
+	switch e.EventType {
+	case "guess":
+		g.Guesses++
+		g.Solved = e.Data["correct"] == "true"
+		if g.Solved || g.Guesses >= MaxGuesses {
+			g.Finished = true
+			g.FinishedAt = e.Timestamp
+		}
+	case "hint":
+		g.Hints++
+	}
+	hs.dirty = true
+}
+
+// Merge moves from's games to to, for when an account claims an anonymous
+// player ID. Where both played the same game, the finished result is kept,
+// preferring to's.
+func (hs *HistoryStore) Merge(from, to string) {
+	if from == to {
+		return
+	}
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	games, ok := hs.players[from]
+	if !ok {
+		return
+	}
+	dest, ok := hs.players[to]
+	if !ok {
+		dest = make(map[string]*historyGame)
+		hs.players[to] = dest
+	}
+	for gameID, g := range games {
+		if existing, ok := dest[gameID]; ok && (existing.Finished || !g.Finished) {
+			continue
+		}
+		dest[gameID] = g
+	}
+	delete(hs.players, from)
+	hs.dirty = true
+}
+
+func (hs *HistoryStore) game(playerID, gameID string) (historyGame, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	g, ok := hs.players[playerID][gameID]
+	if !ok {
+		return historyGame{}, false
+	}
+	return *g, true
+}
+
+// Result returns the player's result for the game on day, if they finished
+// it on the day. Archive plays are not returned.
+func (hs *HistoryStore) Result(playerID string, day time.Time) (GameResult, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	g, ok := hs.players[playerID][day.Format(gameIDLayout)]
+	if !ok || !g.Finished || g.Archive {
+		return GameResult{}, false
+	}
+	return GameResult{Guesses: g.Guesses, Hints: g.Hints, Solved: g.Solved}, true
+}
+
+// PlayerHistory summarises a player's finished games. today is the live
+// game's date: a streak stays current until a day is missed, so not having
+// played today yet does not break it.
+func (hs *HistoryStore) PlayerHistory(playerID string, today time.Time) (*PlayerHistory, error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	games, ok := hs.players[playerID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPlayer, playerID)
+	}
+
+	h := &PlayerHistory{GuessDistribution: make([]int, MaxGuesses)}
+	var solvedDays []time.Time
+	lost := make(map[string]bool)
+	for gameID, g := range games {
+		if !g.Finished {
+			continue
+		}
+		h.Played++
+		if gameID > h.LastPlayed {
+			h.LastPlayed = gameID
+		}
+		if g.Solved {
+			h.Won++
+			if g.Guesses >= 1 && g.Guesses <= MaxGuesses {
+				h.GuessDistribution[g.Guesses-1]++
+			}
+		}
+		if g.Archive {
+			continue
+		}
+		if !g.Solved {
+			lost[gameID] = true
+			continue
+		}
+		if day, err := time.Parse(gameIDLayout, gameID); err == nil {
+			solvedDays = append(solvedDays, day)
+		}
+	}
+	if h.Played > 0 {
+		h.WinPercentage = 100 * float64(h.Won) / float64(h.Played)
+	}
+
+	sort.Slice(solvedDays, func(i, j int) bool { return solvedDays[i].Before(solvedDays[j]) })
+	run := 0
+	for i, day := range solvedDays {
+		if i > 0 && day.Equal(solvedDays[i-1].AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		h.MaxStreak = max(h.MaxStreak, run)
+	}
+
+	solved := make(map[string]bool, len(solvedDays))
+	for _, day := range solvedDays {
+		solved[day.Format(gameIDLayout)] = true
+	}
+	day := today
+	if !solved[day.Format(gameIDLayout)] && !lost[day.Format(gameIDLayout)] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for solved[day.Format(gameIDLayout)] {
+		h.CurrentStreak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return h, nil
+}
+
+// Run writes a snapshot whenever history has changed, and once more when
+// ctx is cancelled.
+func (hs *HistoryStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(historySnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := hs.Snapshot(); err != nil {
+				log.Printf("history snapshot failed: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := hs.Snapshot(); err != nil {
+				log.Printf("history snapshot failed: %v", err)
+			}
+		}
+	}
+}
+
+func (hs *HistoryStore) Snapshot() error {
+	if hs.path == "" {
+		return nil
+	}
+	hs.mu.Lock()
+	if !hs.dirty {
+		hs.mu.Unlock()
+		return nil
+	}
+	raw, err := json.Marshal(hs.players)
+	hs.dirty = false
+	hs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(hs.path, raw); err != nil {
+		hs.mu.Lock()
+		hs.dirty = true
+		hs.mu.Unlock()
+		return err
+	}
+	return nil
+}
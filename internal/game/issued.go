@@ -0,0 +1,62 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// IssuedLog remembers which answer each day was first served with, so a past
+// game keeps its answer when puzzles are added to or removed from the pool
+// that undated days are picked from. It is rewritten whenever a day is added.
+type IssuedLog struct {
+	mu   sync.Mutex
+	path string
+	days map[string]string
+}
+
+func NewIssuedLog(path string) (*IssuedLog, error) {
+	il := &IssuedLog{path: path, days: make(map[string]string)}
+	if path == "" {
+		return il, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return il, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read issued puzzles: %w", err)
+	}
+	if err := json.Unmarshal(raw, &il.days); err != nil {
+		return nil, fmt.Errorf("decode issued puzzles: %w", err)
+	}
+	return il, nil
+}
+
+// Answer returns the answer that went live on gameID's day.
+func (il *IssuedLog) Answer(gameID string) (string, bool) {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	answer, ok := il.days[gameID]
+	return answer, ok
+}
+
+// Record notes answer as the one live on gameID's day.
+func (il *IssuedLog) Record(gameID, answer string) error {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	if il.days[gameID] == answer {
+		return nil
+	}
+	il.days[gameID] = answer
+	if il.path == "" {
+		return nil
+	}
+	raw, err := json.Marshal(il.days)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(il.path, raw)
+}
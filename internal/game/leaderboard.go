@@ -0,0 +1,60 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type LeaderboardEntry struct {
+	PlayerID string
+	Rank     int
+	Guesses  int
+	Hints    int
+	SolvedAt time.Time
+}
+
+// Leaderboard ranks a game's solvers by fewest guesses, then fewest hints,
+// then earliest solve. Players who only played it from the archive are left
+// out, as they could look the answer up, and so is any solve recorded after
+// the player's last guess.
+func (a *StatsAggregator) Leaderboard(gameID string) ([]LeaderboardEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	gs, ok := a.games[gameID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGame, gameID)
+	}
+	var entries []LeaderboardEntry
+	for id, rec := range gs.Players {
+		// Records from before guesses were capped can show a solve past
+		// the last guess; those are not ranked.
+		if !rec.Solved || rec.Archive || rec.Guesses > MaxGuesses {
+			continue
+		}
+		entries = append(entries, LeaderboardEntry{
+			PlayerID: id,
+			Guesses:  rec.Guesses,
+			Hints:    rec.Hints,
+			SolvedAt: rec.SolvedAt,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		x, y := entries[i], entries[j]
+		if x.Guesses != y.Guesses {
+			return x.Guesses < y.Guesses
+		}
+		if x.Hints != y.Hints {
+			return x.Hints < y.Hints
+		}
+		if !x.SolvedAt.Equal(y.SolvedAt) {
+			return x.SolvedAt.Before(y.SolvedAt)
+		}
+		return x.PlayerID < y.PlayerID
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries, nil
+}
@@ -0,0 +1,110 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxDisplayNameLength = 24
+
+var ErrInvalidDisplayName = errors.New("invalid display name")
+
+// NameStore keeps the optional display names players choose for
+// leaderboards, keyed by player ID. It is rewritten on every change.
+type NameStore struct {
+	mu    sync.Mutex
+	path  string
+	names map[string]string
+}
+
+func NewNameStore(path string) (*NameStore, error) {
+	ns := &NameStore{path: path, names: make(map[string]string)}
+	if path == "" {
+		return ns, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ns, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read display names: %w", err)
+	}
+	if err := json.Unmarshal(raw, &ns.names); err != nil {
+		return nil, fmt.Errorf("decode display names: %w", err)
+	}
+	return ns, nil
+}
+
+// Get returns the player's display name, or "" if they have not set one.
+func (ns *NameStore) Get(playerID string) string {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.names[playerID]
+}
+
+// Set changes the player's display name. An empty name removes it.
+func (ns *NameStore) Set(playerID, name string) (string, error) {
+	name, err := NormaliseDisplayName(name)
+	if err != nil {
+		return "", err
+	}
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if name == "" {
+		delete(ns.names, playerID)
+	} else {
+		ns.names[playerID] = name
+	}
+	return name, ns.save()
+}
+
+// Merge moves from's display name to to, for when an account claims an
+// anonymous player ID. A name to already has is kept.
+func (ns *NameStore) Merge(from, to string) error {
+	if from == to {
+		return nil
+	}
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	name, ok := ns.names[from]
+	if !ok {
+		return nil
+	}
+	if _, taken := ns.names[to]; !taken {
+		ns.names[to] = name
+	}
+	delete(ns.names, from)
+	return ns.save()
+}
+
+func (ns *NameStore) save() error {
+	if ns.path == "" {
+		return nil
+	}
+	raw, err := json.Marshal(ns.names)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ns.path, raw)
+}
+
+// NormaliseDisplayName collapses whitespace and checks the name is short and
+// printable.
+func NormaliseDisplayName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return "", fmt.Errorf("%w: at most %d characters", ErrInvalidDisplayName, maxDisplayNameLength)
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return "", fmt.Errorf("%w: unprintable character", ErrInvalidDisplayName)
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,52 @@
+package game
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const rotateRetryInterval = time.Minute
+
+type Scheduler struct {
+	game  *Game
+	retry time.Duration
+}
+
+func NewScheduler(g *Game) *Scheduler {
+	return &Scheduler{game: g, retry: rotateRetryInterval}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	if s.game.Current().Missing {
+		s.rotate(ctx)
+	}
+	for {
+		next := s.game.NextRollover(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.rotate(ctx)
+	}
+}
+
+func (s *Scheduler) rotate(ctx context.Context) {
+	for {
+		err := s.game.Rotate(time.Now())
+		if err == nil {
+			pruned := s.game.Sessions.Prune(time.Now().Add(-sessionTTL))
+			log.Printf("puzzle rotated: game %s (%d stale sessions pruned)", s.game.GetDailyGameID(), pruned)
+			return
+		}
+		log.Printf("puzzle rotation failed, retrying in %s: %v", s.retry, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retry):
+		}
+	}
+}
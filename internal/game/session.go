@@ -0,0 +1,324 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	sessionTTL              = 48 * time.Hour
+	sessionSnapshotInterval = 10 * time.Second
+)
+
+type Session struct {
+	mu                sync.Mutex
+	PlayerID          string
+	GameID            string
+	RevealedPositions map[int]bool
+	Guesses           int
+	NearMisses        int
+	HintsOpened       []string
+	// Feedback holds the letter results of each scored guess, in order.
+	Feedback [][]LetterResult
+	Solved   bool
+	LastSeen time.Time
+}
+
+type SessionState struct {
+	PlayerID          string
+	GameID            string
+	RevealedPositions map[int]bool
+	Guesses           int
+	RemainingGuesses  int
+	HintsOpened       []string
+	Feedback          [][]LetterResult
+	Solved            bool
+	Finished          bool
+}
+
+type sessionKey struct {
+	gameID   string
+	playerID string
+}
+
+// SessionStore keeps every player's in-progress games. It is snapshotted
+// to disk so a restart does not hand players fresh guesses, and sessions
+// missing from the snapshot are rebuilt from history.
+type SessionStore struct {
+	mu       sync.Mutex
+	sessions map[sessionKey]*Session
+	history  *HistoryStore
+	path     string
+	dirty    bool
+	saved    time.Time
+}
+
+func NewSessionStore(path string, history *HistoryStore) (*SessionStore, error) {
+	st := &SessionStore{sessions: make(map[sessionKey]*Session), history: history, path: path}
+	if path == "" {
+		return st, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session snapshot: %w", err)
+	}
+	var list []*Session
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("decode session snapshot: %w", err)
+	}
+	for _, s := range list {
+		if s.RevealedPositions == nil {
+			s.RevealedPositions = make(map[int]bool)
+		}
+		st.catchUp(s)
+		st.sessions[sessionKey{gameID: s.GameID, playerID: s.PlayerID}] = s
+	}
+	st.saved = time.Now()
+	return st, nil
+}
+
+// Get returns the player's session for gameID, creating it on first use.
+func (st *SessionStore) Get(gameID, playerID string) *Session {
+	key := sessionKey{gameID: gameID, playerID: playerID}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if s, ok := st.sessions[key]; ok {
+		return s
+	}
+	s, ok := st.fromHistory(key)
+	if !ok {
+		s = &Session{
+			PlayerID:          playerID,
+			GameID:            gameID,
+			RevealedPositions: make(map[int]bool),
+		}
+	}
+	st.sessions[key] = s
+	return s
+}
+
+func (st *SessionStore) Lookup(gameID, playerID string) (*Session, bool) {
+	key := sessionKey{gameID: gameID, playerID: playerID}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if s, ok := st.sessions[key]; ok {
+		return s, true
+	}
+	s, ok := st.fromHistory(key)
+	if ok {
+		st.sessions[key] = s
+	}
+	return s, ok
+}
+
+// Merge moves from's sessions to to, for when an account claims an anonymous
+// player ID, so a game in progress carries on. Where to has already started
+// the same game, to's session is kept.
+func (st *SessionStore) Merge(from, to string) {
+	if from == to {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for key, s := range st.sessions {
+		if key.playerID != from {
+			continue
+		}
+		delete(st.sessions, key)
+		st.dirty = true
+		dest := sessionKey{gameID: key.gameID, playerID: to}
+		if existing, ok := st.sessions[dest]; ok && existing.started() {
+			continue
+		}
+		s.mu.Lock()
+		s.PlayerID = to
+		s.mu.Unlock()
+		st.sessions[dest] = s
+	}
+}
+
+func (s *Session) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Guesses > 0 || s.NearMisses > 0 || s.Solved || len(s.HintsOpened) > 0
+}
+
+// catchUp applies guesses history recorded after the session snapshot was
+// taken.
+func (st *SessionStore) catchUp(s *Session) {
+	if st.history == nil {
+		return
+	}
+	g, ok := st.history.game(s.PlayerID, s.GameID)
+	if !ok {
+		return
+	}
+	s.Guesses = max(s.Guesses, min(g.Guesses, MaxGuesses))
+	s.Solved = s.Solved || g.Solved
+	if g.Finished && !g.Solved {
+		s.Guesses = MaxGuesses
+	}
+}
+
+// fromHistory rebuilds a session the snapshot missed from the player's
+// recorded guesses, so guesses already used stay used. Which hints were
+// opened is not recorded, so they are not restored.
+func (st *SessionStore) fromHistory(key sessionKey) (*Session, bool) {
+	if st.history == nil {
+		return nil, false
+	}
+	g, ok := st.history.game(key.playerID, key.gameID)
+	if !ok || g.Guesses == 0 {
+		return nil, false
+	}
+	s := &Session{
+		PlayerID:          key.playerID,
+		GameID:            key.gameID,
+		RevealedPositions: make(map[int]bool),
+		Guesses:           min(g.Guesses, MaxGuesses),
+		Solved:            g.Solved,
+		LastSeen:          time.Now(),
+	}
+	if g.Finished && !g.Solved {
+		s.Guesses = MaxGuesses
+	}
+	return s, true
+}
+
+// Prune drops sessions that have not been touched since before.
+func (st *SessionStore) Prune(before time.Time) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	removed := 0
+	for key, s := range st.sessions {
+		s.mu.Lock()
+		stale := s.LastSeen.Before(before)
+		s.mu.Unlock()
+		if stale {
+			delete(st.sessions, key)
+			removed++
+		}
+	}
+	if removed > 0 {
+		st.dirty = true
+	}
+	return removed
+}
+
+// Run writes a snapshot whenever a session has changed, and once more when
+// ctx is cancelled.
+func (st *SessionStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(sessionSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := st.Snapshot(); err != nil {
+				log.Printf("session snapshot failed: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := st.Snapshot(); err != nil {
+				log.Printf("session snapshot failed: %v", err)
+			}
+		}
+	}
+}
+
+// Snapshot writes every session if any was touched since the last one.
+// Each session is copied under its own lock, so a guess in progress is
+// either fully in the snapshot or not at all.
+func (st *SessionStore) Snapshot() error {
+	if st.path == "" {
+		return nil
+	}
+	st.mu.Lock()
+	started := time.Now()
+	changed := st.dirty
+	list := make([]json.RawMessage, 0, len(st.sessions))
+	for _, s := range st.sessions {
+		s.mu.Lock()
+		if !s.LastSeen.Before(st.saved) {
+			changed = true
+		}
+		raw, err := json.Marshal(s)
+		s.mu.Unlock()
+		if err != nil {
+			st.mu.Unlock()
+			return err
+		}
+		list = append(list, raw)
+	}
+	if !changed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.dirty = false
+	prevSaved := st.saved
+	st.saved = started
+	st.mu.Unlock()
+
+	raw, err := json.Marshal(list)
+	if err == nil {
+		err = writeFileAtomic(st.path, raw)
+	}
+	if err != nil {
+		st.mu.Lock()
+		st.dirty = true
+		st.saved = prevSaved
+		st.mu.Unlock()
+	}
+	return err
+}
+
+// OpenHint records category as opened and returns the number of distinct
+// hints the player has used and whether this call opened a new one.
+// Reopening a hint does not count twice.
+func (s *Session) OpenHint(category string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastSeen = time.Now()
+	for _, c := range s.HintsOpened {
+		if c == category {
+			return len(s.HintsOpened), false, nil
+		}
+	}
+	if s.finished() {
+		return len(s.HintsOpened), false, ErrGameOver
+	}
+	s.HintsOpened = append(s.HintsOpened, category)
+	return len(s.HintsOpened), true, nil
+}
+
+func (s *Session) finished() bool { return s.Solved || s.Guesses >= MaxGuesses }
+
+func (s *Session) State() SessionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revealed := make(map[int]bool, len(s.RevealedPositions))
+	for k, v := range s.RevealedPositions {
+		revealed[k] = v
+	}
+	return SessionState{
+		PlayerID:          s.PlayerID,
+		GameID:            s.GameID,
+		RevealedPositions: revealed,
+		Guesses:           s.Guesses,
+		RemainingGuesses:  MaxGuesses - s.Guesses,
+		HintsOpened:       append([]string(nil), s.HintsOpened...),
+		Feedback:          append([][]LetterResult(nil), s.Feedback...),
+		Solved:            s.Solved,
+		Finished:          s.finished(),
+	}
+}
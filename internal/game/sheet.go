@@ -2,12 +2,10 @@ package game
 
 import (
 	"context"
-	"encoding/csv"
 	"fmt"
 	"log"
-	"math/rand"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/api/option"
@@ -17,93 +15,107 @@ import (
 )
 
 type Sheet struct {
-	prod      bool
 	service   *sheets.Service
 	sheetID   string
-	static    [][]string
 	analytics *Analytics
 }
 type PlayerStats struct {
-	TotalPlayers  int    `json:"totalPlayers"`
-	PlayersSolved int    `json:"playersSolved"`
-	PlayerRank    int    `json:"playerRank"`
-	SolveTime     string `json:"solveTime,omitempty"`
+	TotalPlayers  int  `json:"totalPlayers"`
+	PlayersSolved int  `json:"playersSolved"`
+	Solved        bool `json:"solved"`
+	// PlayerRank is the 1-based solve position, or 0 if the player has not
+	// solved the puzzle.
+	PlayerRank  int    `json:"playerRank"`
+	SolveTime   string `json:"solveTime,omitempty"`
+	GuessesUsed int    `json:"guessesUsed"`
+	HintsUsed   int    `json:"hintsUsed"`
+	// Percentile is the share of solvers who solved after the player, 0-100.
+	Percentile        float64     `json:"percentile"`
+	GuessDistribution []int       `json:"guessDistribution"`
+	HintDistribution  map[int]int `json:"hintDistribution"`
 }
 
 type WordData struct {
+	// StoreID is the puzzle's ID in its source's List, so an edit can be
+	// matched to the puzzle it changes.
+	StoreID        string
 	Answer         string
+	PublishDate    time.Time
 	Hints          map[string]string
 	Categories     map[string]string
 	CategoryEmojis map[string]string
 	CategoryOrder  []string
+	Alternates     []string
+	// Feedback overrides FEEDBACK_MODE for this puzzle when set.
+	Feedback FeedbackMode
 }
 
 type Analytics struct {
-	service    *sheets.Service
-	sheetID    string
-	eventsChan chan Event
-	sheetCache map[string]struct{}
+	sink  EventSink
+	spool *Spool
+	stop  context.CancelFunc
+	done  chan struct{}
+	once  sync.Once
 }
 
 type Event struct {
-	GameID    string
-	PlayerID  string
-	EventType string
-	Data      map[string]string
-	Timestamp time.Time
+	GameID    string            `json:"gameId"`
+	PlayerID  string            `json:"playerId"`
+	EventType string            `json:"type"`
+	Data      map[string]string `json:"data,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
 }
 
 func NewSheet(cfg config.Config) (*Sheet, error) {
-	if cfg.Mode == config.ModeLocal {
-		rows, err := csv.NewReader(strings.NewReader(staticCSV)).ReadAll()
+	sinkNames := analyticsSinkNames(cfg)
+	s := &Sheet{}
+
+	needsSheets := cfg.PuzzleSource == SourceSheets || (cfg.Mode == config.ModeProd && cfg.PuzzleSource == "")
+	for _, name := range sinkNames {
+		needsSheets = needsSheets || name == SinkSheets
+	}
+	if needsSheets {
+		ctx := context.Background()
+		svc, err := sheets.NewService(ctx, sheetsClientOptions(cfg)...)
 		if err != nil {
 			return nil, err
 		}
-		return &Sheet{prod: false, static: rows}, nil
+		s.service = svc
+		s.sheetID = cfg.WordSheetID
 	}
 
-	ctx := context.Background()
-	svc, err := sheets.NewService(ctx, option.WithCredentialsFile(cfg.CredentialsJSONPath))
+	sink, err := newEventSink(cfg, sinkNames, s.service)
 	if err != nil {
 		return nil, err
 	}
-	s := &Sheet{
-		prod:    true,
-		service: svc,
-		sheetID: cfg.WordSheetID,
+	if sink != nil {
+		flushInterval, err := time.ParseDuration(cfg.AnalyticsFlushInterval)
+		if err != nil || flushInterval <= 0 {
+			sink.Close()
+			return nil, fmt.Errorf("invalid ANALYTICS_FLUSH_INTERVAL %q", cfg.AnalyticsFlushInterval)
+		}
+		spool, err := OpenSpool(dataPath(cfg, cfg.AnalyticsSpoolDir, "spool"))
+		if err != nil {
+			sink.Close()
+			return nil, fmt.Errorf("open analytics spool: %w", err)
+		}
+		spool.flushInterval = flushInterval
+		s.InitAnalytics(sink, spool)
 	}
-	s.InitAnalytics(cfg.AnalyticsSheetID)
 	return s, nil
 }
 
-func (s *Sheet) GetDailyWord() (*WordData, error) {
-	if !s.prod {
-		return parseWordData(interfaceSlice(s.static[s.randomIndex()]))
+// sheetsClientOptions points the client at SHEETS_ENDPOINT when set, so the
+// app can run against a local fake without credentials.
+func sheetsClientOptions(cfg config.Config) []option.ClientOption {
+	if cfg.SheetsEndpoint == "" {
+		return []option.ClientOption{option.WithCredentialsFile(cfg.CredentialsJSONPath)}
 	}
-
-	readRange := "Sheet1!A2:M"
-	resp, err := s.service.Spreadsheets.Values.Get(s.sheetID, readRange).Do()
-	if err != nil {
-		return nil, fmt.Errorf("read prod sheet: %w", err)
+	opts := []option.ClientOption{option.WithEndpoint(cfg.SheetsEndpoint)}
+	if cfg.CredentialsJSONPath == "" {
+		return append(opts, option.WithoutAuthentication())
 	}
-	if len(resp.Values) == 0 {
-		return nil, fmt.Errorf("no rows in prod sheet")
-	}
-
-	idx := s.randomIndexWithLen(len(resp.Values))
-	return parseWordData(resp.Values[idx])
-}
-
-func (s *Sheet) randomIndex() int { return s.randomIndexWithLen(len(s.static)) }
-func (s *Sheet) randomIndexWithLen(n int) int {
-	if UseSequentialDailyWord {
-		now := time.Now().Truncate(24 * time.Hour)
-		start := dailyWordStartDate.Truncate(24 * time.Hour)
-		days := int(now.Sub(start).Hours() / 24)
-		return days % n
-	}
-	rand.Seed(time.Now().UnixNano())
-	return rand.Intn(n)
+	return append(opts, option.WithCredentialsFile(cfg.CredentialsJSONPath))
 }
 
 func interfaceSlice(ss []string) []interface{} {
@@ -114,6 +126,12 @@ func interfaceSlice(ss []string) []interface{} {
 	return out
 }
 
+const (
+	publishDateColumn = 13
+	alternatesColumn  = 14
+	feedbackColumn    = 15
+)
+
 func parseWordData(row []interface{}) (*WordData, error) {
 	expectedColumns := 13
 	if len(row) < expectedColumns {
@@ -152,256 +170,108 @@ func parseWordData(row []interface{}) (*WordData, error) {
 		return nil, fmt.Errorf("no valid categories parsed from the row")
 	}
 
-	return data, nil
-}
-
-func (s *Sheet) InitAnalytics(sheetID string) {
-	s.analytics = &Analytics{
-		sheetID:    sheetID,
-		eventsChan: make(chan Event, 1000),
-		service:    s.service,
-		sheetCache: make(map[string]struct{}),
-	}
-	go s.analytics.processEvents()
-}
-
-func (a *Analytics) processEvents() {
-	for event := range a.eventsChan {
-		a.writeEventToSheet(event)
-	}
-}
-
-func (a *Analytics) writeEventToSheet(event Event) {
-
-	sheetName := fmt.Sprintf("Game-%s", event.GameID)
-
-	if _, exists := a.sheetCache[sheetName]; !exists {
-		exists, err := a.sheetExists(sheetName)
-		if err != nil {
-			log.Printf("Sheet check failed: %v", err)
-			return
-		}
-
-		if !exists {
-			if err := a.createSheetWithHeaders(sheetName); err != nil {
-				log.Printf("Sheet creation failed: %v", err)
-				return
+	if len(row) > publishDateColumn {
+		if raw := strings.TrimSpace(fmt.Sprint(row[publishDateColumn])); raw != "" {
+			date, err := time.Parse(gameIDLayout, raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid publish date %q: %w", raw, err)
 			}
+			data.PublishDate = date
 		}
-
-		a.sheetCache[sheetName] = struct{}{}
 	}
-
-	values := &sheets.ValueRange{
-		Values: [][]interface{}{
-			{
-				event.Timestamp.Format(time.RFC3339),
-				event.EventType,
-				event.Data["correct"],
-				event.Data["guess"],
-				event.Data["category"],
-				event.PlayerID,
-			},
-		},
+	if len(row) > alternatesColumn {
+		data.Alternates = splitAlternates(fmt.Sprint(row[alternatesColumn]))
 	}
-
-	_, err := a.service.Spreadsheets.Values.Append(
-		a.sheetID,
-		fmt.Sprintf("%s!A1", sheetName),
-		values,
-	).ValueInputOption("USER_ENTERED").Do()
-
-	if err != nil {
-		log.Printf("Failed to log event: %v", err)
+	if len(row) > feedbackColumn {
+		if raw := strings.TrimSpace(fmt.Sprint(row[feedbackColumn])); raw != "" {
+			mode, err := ParseFeedbackMode(raw)
+			if err != nil {
+				return nil, err
+			}
+			data.Feedback = mode
+		}
 	}
-}
 
-func (s *Sheet) LogEvent(event Event) {
-	if s.analytics == nil {
-		return
-	}
-	select {
-	case s.analytics.eventsChan <- event:
-	default:
-	}
+	return data, nil
 }
 
-func (a *Analytics) sheetExists(sheetName string) (bool, error) {
-	resp, err := a.service.Spreadsheets.Get(a.sheetID).Do()
-	if err != nil {
-		return false, err
-	}
-
-	for _, sheet := range resp.Sheets {
-		if sheet.Properties.Title == sheetName {
-			return true, nil
+// splitAlternates reads the alternates cell, a list separated by semicolons.
+func splitAlternates(cell string) []string {
+	var out []string
+	for _, alt := range strings.Split(cell, ";") {
+		if alt = strings.TrimSpace(alt); alt != "" {
+			out = append(out, alt)
 		}
 	}
-	return false, nil
+	return out
 }
 
-func (a *Analytics) createSheetWithHeaders(sheetName string) error {
-
-	addReq := &sheets.BatchUpdateSpreadsheetRequest{
-		Requests: []*sheets.Request{
-			{
-				AddSheet: &sheets.AddSheetRequest{
-					Properties: &sheets.SheetProperties{
-						Title: sheetName,
-					},
-				},
-			},
-		},
-	}
-
-	_, err := a.service.Spreadsheets.BatchUpdate(a.sheetID, addReq).Do()
-	if err != nil {
-		return err
-	}
-
-	headerValues := &sheets.ValueRange{
-		Values: [][]interface{}{
-			{"Timestamp", "Event Type", "Correct", "Guess", "Category", "PlayerID"},
-		},
-	}
-
-	_, err = a.service.Spreadsheets.Values.Update(
-		a.sheetID,
-		fmt.Sprintf("%s!A1", sheetName),
-		headerValues,
-	).ValueInputOption("USER_ENTERED").Do()
-	if err != nil {
-		return err
-	}
-
-	statsValues := &sheets.ValueRange{
-		Values: [][]interface{}{
-			{"STATISTICS"},
-			{"Total Players", "=COUNTA(UNIQUE(F2:F1000))"},
-			{"Players Solved", "=COUNTA(UNIQUE(FILTER(F2:F, B2:B=\"guess\", C2:C=TRUE)))"},
-			{"Solve Rate", "=I3/I2"},
-		},
-	}
-
-	_, err = a.service.Spreadsheets.Values.Update(
-		a.sheetID,
-		fmt.Sprintf("%s!H1", sheetName),
-		statsValues,
-	).ValueInputOption("USER_ENTERED").Do()
-	if err != nil {
-		return err
-	}
-
-	rankHeaderValues := &sheets.ValueRange{
-		Values: [][]interface{}{
-			{"PLAYER RANKINGS"},
-			{"PlayerID"},
-		},
-	}
-
-	_, err = a.service.Spreadsheets.Values.Update(
-		a.sheetID,
-		fmt.Sprintf("%s!K1", sheetName),
-		rankHeaderValues,
-	).ValueInputOption("USER_ENTERED").Do()
-	if err != nil {
-		return err
-	}
-
-	playerListFormula := &sheets.ValueRange{
-		Values: [][]interface{}{
-			{"=UNIQUE(FILTER(F2:F, B2:B=\"guess\", C2:C=TRUE))"},
-		},
-	}
-
-	_, err = a.service.Spreadsheets.Values.Update(
-		a.sheetID,
-		fmt.Sprintf("%s!K3", sheetName),
-		playerListFormula,
-	).ValueInputOption("USER_ENTERED").Do()
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (s *Sheet) InitAnalytics(sink EventSink, spool *Spool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.analytics = &Analytics{
+		sink:  sink,
+		spool: spool,
+		stop:  cancel,
+		done:  make(chan struct{}),
+	}
+	go func() {
+		spool.Ship(ctx, sink)
+		close(s.analytics.done)
+	}()
 }
 
-func (s *Sheet) GetPlayerStats(gameID, playerID string) (*PlayerStats, error) {
-	sheetName := fmt.Sprintf("Game-%s", strings.Trim(gameID, "\""))
+// StopAnalytics stops the shipper and sends whatever is left in the spool,
+// giving up when ctx is done. Events it cannot send stay in the spool and are
+// replayed on the next start if the disk survives.
+func (s *Sheet) StopAnalytics(ctx context.Context) error {
+	if s.analytics == nil {
+		return nil
+	}
+	a := s.analytics
+	var err error
+	a.once.Do(func() {
+		before := a.spool.Counters()
+		a.stop()
+
+		drained := make(chan error, 1)
+		go func() {
+			<-a.done
+			drained <- a.spool.Drain(ctx, a.sink)
+		}()
+		select {
+		case err = <-drained:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
 
-	if _, exists := s.analytics.sheetCache[sheetName]; !exists {
-		exists, err := s.analytics.sheetExists(sheetName)
+		after := a.spool.Counters()
+		log.Printf("analytics: flushed %d events, dropped %d, abandoned %d in spool",
+			after.Shipped-before.Shipped, after.Dropped-before.Dropped, after.Pending)
 		if err != nil {
-			log.Printf("Sheet check failed: %v", err)
-			return &PlayerStats{
-				TotalPlayers:  1,
-				PlayersSolved: 1,
-				PlayerRank:    1,
-			}, nil
+			return
 		}
-
-		if !exists {
-			log.Printf("Returning 1s: %v", sheetName)
-			return &PlayerStats{
-				TotalPlayers:  1,
-				PlayersSolved: 1,
-				PlayerRank:    1,
-			}, nil
+		if cerr := a.sink.Close(); cerr != nil {
+			log.Printf("close analytics sink: %v", cerr)
 		}
-
-		s.analytics.sheetCache[sheetName] = struct{}{}
-	}
-
-	statsRange := fmt.Sprintf("%s!I2:I3", sheetName)
-	statsResp, err := s.service.Spreadsheets.Values.Get(s.sheetID, statsRange).Do()
-	if err != nil {
-		log.Printf("Warning: Failed to get stats: %v", err)
-		return &PlayerStats{
-			TotalPlayers:  1,
-			PlayersSolved: 1,
-			PlayerRank:    1,
-		}, nil
-	}
-
-	stats := &PlayerStats{
-		TotalPlayers:  1,
-		PlayersSolved: 1,
-		PlayerRank:    1,
-	}
-
-	if len(statsResp.Values) >= 1 && len(statsResp.Values[0]) >= 1 {
-		totalStr := fmt.Sprint(statsResp.Values[0][0])
-		totalPlayers, err := strconv.Atoi(totalStr)
-		if err == nil && totalPlayers > 0 {
-			stats.TotalPlayers = totalPlayers
+		if cerr := a.spool.Close(); cerr != nil {
+			log.Printf("close analytics spool: %v", cerr)
 		}
-	}
+	})
+	return err
+}
 
-	if len(statsResp.Values) >= 2 && len(statsResp.Values[1]) >= 1 {
-		solvedStr := fmt.Sprint(statsResp.Values[1][0])
-		playersSolved, err := strconv.Atoi(solvedStr)
-		if err == nil && playersSolved > 0 {
-			stats.PlayersSolved = playersSolved
-		}
+func (s *Sheet) AnalyticsCounters() (SpoolCounters, bool) {
+	if s.analytics == nil {
+		return SpoolCounters{}, false
 	}
+	return s.analytics.spool.Counters(), true
+}
 
-	rankingRange := fmt.Sprintf("%s!K3:K", sheetName)
-	rankingResp, err := s.service.Spreadsheets.Values.Get(s.sheetID, rankingRange).Do()
-	if err != nil {
-		log.Printf("Warning: Failed to get player rankings: %v", err)
-		return stats, nil
+func (s *Sheet) LogEvent(event Event) {
+	if s.analytics == nil {
+		return
 	}
-
-	for rank, row := range rankingResp.Values {
-		rowPlayerID := fmt.Sprint(row[0])
-		if rowPlayerID == "" {
-			continue
-		}
-
-		if strings.TrimSpace(rowPlayerID) == strings.TrimSpace(playerID) {
-			stats.PlayerRank = rank
-			break
-		}
+	if err := s.analytics.spool.Append(event); err != nil {
+		log.Printf("Failed to spool event: %v", err)
 	}
-	return stats, nil
 }
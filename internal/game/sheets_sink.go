@@ -0,0 +1,356 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/sheets/v4"
+
+	"references/internal/config"
+)
+
+const (
+	sheetsBaseBackoff = 500 * time.Millisecond
+	sheetsMaxBackoff  = 30 * time.Second
+)
+
+type SheetsSinkOptions struct {
+	// BatchSize is the number of buffered rows for one tab that triggers a
+	// flush of that tab before the next time-based flush.
+	BatchSize int
+	// WritesPerMinute caps the requests the sink sends to the Sheets API.
+	WritesPerMinute int
+	// MaxRetries is how many times a request failing with 429 or 5xx is
+	// retried before the error is returned.
+	MaxRetries int
+}
+
+func sheetsSinkOptions(cfg config.Config) (SheetsSinkOptions, error) {
+	var opts SheetsSinkOptions
+	for _, f := range []struct {
+		name string
+		raw  string
+		dst  *int
+		min  int
+	}{
+		{"SHEETS_BATCH_SIZE", cfg.SheetsBatchSize, &opts.BatchSize, 1},
+		{"SHEETS_WRITES_PER_MINUTE", cfg.SheetsWritesPerMinute, &opts.WritesPerMinute, 1},
+		{"SHEETS_MAX_RETRIES", cfg.SheetsMaxRetries, &opts.MaxRetries, 0},
+	} {
+		n, err := strconv.Atoi(f.raw)
+		if err != nil || n < f.min {
+			return opts, fmt.Errorf("invalid %s %q", f.name, f.raw)
+		}
+		*f.dst = n
+	}
+	return opts, nil
+}
+
+// SheetsSink buffers events per Game-<date> tab and appends each tab's rows
+// in a single request when flushed. A tab that fails keeps its rows for the
+// next flush, and events the spool resends are skipped if their rows are
+// already buffered or appended.
+type SheetsSink struct {
+	service *sheets.Service
+	sheetID string
+	opts    SheetsSinkOptions
+	limiter *rateLimiter
+
+	mu      sync.Mutex
+	tabs    map[string]struct{}
+	pending map[string][][]interface{}
+	// seen holds the events buffered or appended since the spool last caught
+	// up, keyed by their JSON; pendingKeys lists the buffered ones per tab.
+	seen        map[string]bool
+	pendingKeys map[string][]string
+}
+
+func NewSheetsSink(service *sheets.Service, sheetID string, opts SheetsSinkOptions) *SheetsSink {
+	return &SheetsSink{
+		service:     service,
+		sheetID:     sheetID,
+		opts:        opts,
+		limiter:     newRateLimiter(opts.WritesPerMinute),
+		pending:     make(map[string][][]interface{}),
+		seen:        make(map[string]bool),
+		pendingKeys: make(map[string][]string),
+	}
+}
+
+func (a *SheetsSink) WriteEvent(ctx context.Context, event Event) error {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return rejected(err)
+	}
+	key := string(raw)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.seen[key] {
+		return nil
+	}
+	a.seen[key] = true
+
+	tab := fmt.Sprintf("Game-%s", event.GameID)
+	a.pendingKeys[tab] = append(a.pendingKeys[tab], key)
+	a.pending[tab] = append(a.pending[tab], []interface{}{
+		event.Timestamp.Format(time.RFC3339),
+		event.EventType,
+		event.Data["correct"],
+		event.Data["guess"],
+		event.Data["category"],
+		event.PlayerID,
+	})
+	if len(a.pending[tab]) < a.opts.BatchSize {
+		return nil
+	}
+	return a.flushTab(ctx, tab)
+}
+
+func (a *SheetsSink) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tabs := make([]string, 0, len(a.pending))
+	for tab := range a.pending {
+		tabs = append(tabs, tab)
+	}
+	sort.Strings(tabs)
+	for _, tab := range tabs {
+		if err := a.flushTab(ctx, tab); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Committed forgets the appended events once the spool will not resend them.
+func (a *SheetsSink) Committed() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seen = make(map[string]bool)
+	for _, keys := range a.pendingKeys {
+		for _, key := range keys {
+			a.seen[key] = true
+		}
+	}
+}
+
+func (a *SheetsSink) Close() error { return a.Flush(context.Background()) }
+
+// flushTab appends the tab's buffered rows. On success or rejection the rows
+// are dropped; on any other error they stay buffered for the next flush.
+func (a *SheetsSink) flushTab(ctx context.Context, tab string) error {
+	rows := a.pending[tab]
+	if len(rows) == 0 {
+		a.dropTab(tab)
+		return nil
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = a.ensureTab(ctx, tab); err != nil {
+			return err
+		}
+		err = a.call(ctx, func() error {
+			_, err := a.service.Spreadsheets.Values.Append(
+				a.sheetID,
+				fmt.Sprintf("%s!A1", tab),
+				&sheets.ValueRange{Values: rows},
+			).ValueInputOption("USER_ENTERED").Context(ctx).Do()
+			return err
+		})
+		// The tab may have been deleted since it was cached; recreate it.
+		if attempt == 1 && isRangeError(err) {
+			a.tabs = nil
+			continue
+		}
+		break
+	}
+	if err != nil {
+		err = fmt.Errorf("append %d rows to %s: %w", len(rows), tab, err)
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest && !isRangeError(err) {
+			for _, key := range a.pendingKeys[tab] {
+				delete(a.seen, key)
+			}
+			a.dropTab(tab)
+			return rejected(err)
+		}
+		return err
+	}
+	a.dropTab(tab)
+	return nil
+}
+
+func (a *SheetsSink) dropTab(tab string) {
+	delete(a.pending, tab)
+	delete(a.pendingKeys, tab)
+}
+
+// isRangeError reports a 400 about the range rather than the rows, which
+// the Sheets API returns when the tab does not exist.
+func isRangeError(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest &&
+		strings.Contains(apiErr.Message, "Unable to parse range")
+}
+
+// ensureTab creates the tab if it is missing. The list of existing tabs is
+// fetched once and then kept up to date locally.
+func (a *SheetsSink) ensureTab(ctx context.Context, tab string) error {
+	if a.tabs == nil {
+		var resp *sheets.Spreadsheet
+		err := a.call(ctx, func() error {
+			var err error
+			resp, err = a.service.Spreadsheets.Get(a.sheetID).Fields("sheets.properties.title").Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("sheet check failed: %w", err)
+		}
+		a.tabs = make(map[string]struct{}, len(resp.Sheets))
+		for _, sheet := range resp.Sheets {
+			a.tabs[sheet.Properties.Title] = struct{}{}
+		}
+	}
+	if _, ok := a.tabs[tab]; ok {
+		return nil
+	}
+
+	if err := a.createSheetWithHeaders(ctx, tab); err != nil {
+		// Someone else may have created it; re-read the tab list next time.
+		a.tabs = nil
+		return fmt.Errorf("sheet creation failed: %w", err)
+	}
+	a.tabs[tab] = struct{}{}
+	return nil
+}
+
+func (a *SheetsSink) createSheetWithHeaders(ctx context.Context, sheetName string) error {
+	addReq := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{
+			{
+				AddSheet: &sheets.AddSheetRequest{
+					Properties: &sheets.SheetProperties{
+						Title: sheetName,
+					},
+				},
+			},
+		},
+	}
+	err := a.call(ctx, func() error {
+		_, err := a.service.Spreadsheets.BatchUpdate(a.sheetID, addReq).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	headers := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "USER_ENTERED",
+		Data: []*sheets.ValueRange{
+			{
+				Range: fmt.Sprintf("%s!A1", sheetName),
+				Values: [][]interface{}{
+					{"Timestamp", "Event Type", "Correct", "Guess", "Category", "PlayerID"},
+				},
+			},
+			{
+				Range: fmt.Sprintf("%s!H1", sheetName),
+				Values: [][]interface{}{
+					{"STATISTICS"},
+					{"Total Players", "=COUNTA(UNIQUE(F2:F1000))"},
+					{"Players Solved", "=COUNTA(UNIQUE(FILTER(F2:F, B2:B=\"guess\", C2:C=TRUE)))"},
+					{"Solve Rate", "=I3/I2"},
+				},
+			},
+			{
+				Range: fmt.Sprintf("%s!K1", sheetName),
+				Values: [][]interface{}{
+					{"PLAYER RANKINGS"},
+					{"PlayerID"},
+					{"=UNIQUE(FILTER(F2:F, B2:B=\"guess\", C2:C=TRUE))"},
+				},
+			},
+		},
+	}
+	return a.call(ctx, func() error {
+		_, err := a.service.Spreadsheets.Values.BatchUpdate(a.sheetID, headers).Context(ctx).Do()
+		return err
+	})
+}
+
+// call runs one API request under the rate limit, retrying rate-limit and
+// server errors with exponential backoff and jitter. Waiting stops when ctx
+// is done.
+func (a *SheetsSink) call(ctx context.Context, do func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := a.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		err := do()
+		if err == nil || !retryableSheetsError(err) || attempt > a.opts.MaxRetries {
+			return err
+		}
+		delay := backoffDelay(sheetsBaseBackoff, sheetsMaxBackoff, attempt)
+		log.Printf("sheets request failed (attempt %d), retrying in %s: %v", attempt, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func retryableSheetsError(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500
+}
+
+// rateLimiter spaces calls evenly so no more than perMinute start in any
+// minute.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the next call may start, or returns ctx's error if ctx
+// is done first. A cancelled wait still uses up its slot.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
@@ -0,0 +1,262 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+
+	"references/internal/config"
+)
+
+const fakeSheetID = "sheet-id"
+
+// fakeSheets is a Sheets API server holding tabs and appended rows in
+// memory. Appends are answered with 429 while throttle is positive, and
+// always for tabs in down. A row with the guess "bad" is rejected with 400.
+type fakeSheets struct {
+	mu       sync.Mutex
+	tabs     map[string]bool
+	rows     map[string][]string
+	appends  int
+	throttle int
+	down     map[string]bool
+}
+
+func newFakeSheets(t *testing.T) (*fakeSheets, *sheets.Service) {
+	t.Helper()
+	f := &fakeSheets{tabs: make(map[string]bool), rows: make(map[string][]string), down: make(map[string]bool)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	svc, err := sheets.NewService(context.Background(), sheetsClientOptions(config.Config{SheetsEndpoint: srv.URL + "/"})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, svc
+}
+
+func (f *fakeSheets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	base := "/v4/spreadsheets/" + fakeSheetID
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodGet && path == base:
+		var resp sheets.Spreadsheet
+		for tab := range f.tabs {
+			resp.Sheets = append(resp.Sheets, &sheets.Sheet{Properties: &sheets.SheetProperties{Title: tab}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case path == base+":batchUpdate":
+		var req sheets.BatchUpdateSpreadsheetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, q := range req.Requests {
+			f.tabs[q.AddSheet.Properties.Title] = true
+		}
+		w.Write([]byte("{}"))
+	case path == base+"/values:batchUpdate":
+		w.Write([]byte("{}"))
+	case strings.HasPrefix(path, base+"/values/") && strings.HasSuffix(path, ":append"):
+		f.appends++
+		rng := strings.TrimSuffix(strings.TrimPrefix(path, base+"/values/"), ":append")
+		tab, _, _ := strings.Cut(rng, "!")
+		var req sheets.ValueRange
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case !f.tabs[tab]:
+			writeAPIError(w, http.StatusBadRequest, "Unable to parse range: "+rng)
+			return
+		case f.throttle > 0 || f.down[tab]:
+			f.throttle--
+			writeAPIError(w, http.StatusTooManyRequests, "Quota exceeded")
+			return
+		}
+		for _, row := range req.Values {
+			if row[3] == "bad" {
+				writeAPIError(w, http.StatusBadRequest, "Invalid values")
+				return
+			}
+		}
+		for _, row := range req.Values {
+			f.rows[tab] = append(f.rows[tab], fmt.Sprint(row[3]))
+		}
+		w.Write([]byte("{}"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q}}`, code, message)
+}
+
+func (f *fakeSheets) snapshot() (map[string]string, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows := make(map[string]string)
+	for tab, guesses := range f.rows {
+		rows[tab] = strings.Join(guesses, ",")
+	}
+	return rows, f.appends
+}
+
+func guessEvent(gameID, guess string) Event {
+	return Event{GameID: gameID, PlayerID: "p-1", EventType: "guess", Data: map[string]string{"guess": guess}, Timestamp: time.Now()}
+}
+
+func testSinkOptions() SheetsSinkOptions {
+	return SheetsSinkOptions{BatchSize: 2, WritesPerMinute: 60000, MaxRetries: 3}
+}
+
+func TestSheetsSinkBatchesRowsPerTab(t *testing.T) {
+	fake, svc := newFakeSheets(t)
+	sink := NewSheetsSink(svc, fakeSheetID, testSinkOptions())
+	ctx := context.Background()
+
+	for _, e := range []Event{guessEvent("2025-01-01", "a"), guessEvent("2025-01-02", "x"), guessEvent("2025-01-01", "b")} {
+		if err := sink.WriteEvent(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, appends := fake.snapshot()
+	if appends != 1 || rows["Game-2025-01-01"] != "a,b" {
+		t.Fatalf("after a full batch: %d appends, rows %v", appends, rows)
+	}
+
+	sink.WriteEvent(ctx, guessEvent("2025-01-01", "c"))
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, appends = fake.snapshot()
+	if appends != 3 || rows["Game-2025-01-01"] != "a,b,c" || rows["Game-2025-01-02"] != "x" {
+		t.Fatalf("after flush: %d appends, rows %v", appends, rows)
+	}
+}
+
+func TestSheetsSinkRetriesRateLimits(t *testing.T) {
+	fake, svc := newFakeSheets(t)
+	fake.throttle = 2
+	sink := NewSheetsSink(svc, fakeSheetID, testSinkOptions())
+	ctx := context.Background()
+
+	sink.WriteEvent(ctx, guessEvent("2025-01-01", "a"))
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, appends := fake.snapshot()
+	if appends != 3 || rows["Game-2025-01-01"] != "a" {
+		t.Fatalf("%d appends, rows %v", appends, rows)
+	}
+}
+
+func TestSheetsSinkBackoffStopsWithContext(t *testing.T) {
+	fake, svc := newFakeSheets(t)
+	fake.throttle = 1000
+	opts := testSinkOptions()
+	opts.MaxRetries = 1000
+	sink := NewSheetsSink(svc, fakeSheetID, opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	sink.WriteEvent(ctx, guessEvent("2025-01-01", "a"))
+	start := time.Now()
+	if err := sink.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Flush = %v, want deadline exceeded", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Fatalf("Flush returned after %s", waited)
+	}
+}
+
+func TestSheetsSinkKeepsFailedTabs(t *testing.T) {
+	fake, svc := newFakeSheets(t)
+	fake.down["Game-2025-01-02"] = true
+	opts := testSinkOptions()
+	opts.MaxRetries = 0
+	sink := NewSheetsSink(svc, fakeSheetID, opts)
+	ctx := context.Background()
+
+	events := []Event{guessEvent("2025-01-01", "a"), guessEvent("2025-01-02", "x")}
+	for _, e := range events {
+		sink.WriteEvent(ctx, e)
+	}
+	if err := sink.Flush(ctx); err == nil {
+		t.Fatal("Flush succeeded with a tab down")
+	}
+
+	// The spool resends the whole batch after a failure.
+	fake.mu.Lock()
+	fake.down = map[string]bool{}
+	fake.mu.Unlock()
+	for _, e := range events {
+		sink.WriteEvent(ctx, e)
+	}
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := fake.snapshot()
+	if rows["Game-2025-01-01"] != "a" || rows["Game-2025-01-02"] != "x" {
+		t.Fatalf("rows %v", rows)
+	}
+}
+
+func TestSheetsSinkRecreatesDeletedTab(t *testing.T) {
+	fake, svc := newFakeSheets(t)
+	sink := NewSheetsSink(svc, fakeSheetID, testSinkOptions())
+	ctx := context.Background()
+
+	sink.WriteEvent(ctx, guessEvent("2025-01-01", "a"))
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	delete(fake.tabs, "Game-2025-01-01")
+	fake.mu.Unlock()
+
+	sink.WriteEvent(ctx, guessEvent("2025-01-01", "b"))
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := fake.snapshot()
+	if rows["Game-2025-01-01"] != "a,b" {
+		t.Fatalf("rows %v", rows)
+	}
+}
+
+func TestSpoolSkipsOnlyRejectedSheetsRows(t *testing.T) {
+	fake, svc := newFakeSheets(t)
+	sink := NewSheetsSink(svc, fakeSheetID, SheetsSinkOptions{BatchSize: 10, WritesPerMinute: 60000, MaxRetries: 3})
+	spool, err := OpenSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spool.Close()
+	for _, guess := range []string{"a", "bad", "c"} {
+		spool.Append(guessEvent("2025-01-01", guess))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := spool.Drain(ctx, sink); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := fake.snapshot()
+	if rows["Game-2025-01-01"] != "a,c" {
+		t.Fatalf("rows %v", rows)
+	}
+	if c := spool.Counters(); c.Shipped != 2 || c.Dropped != 1 || c.Pending != 0 {
+		t.Fatalf("counters %+v", c)
+	}
+}
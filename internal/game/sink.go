@@ -0,0 +1,321 @@
+package game
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+	_ "modernc.org/sqlite"
+
+	"references/internal/config"
+)
+
+const (
+	SinkSheets = "sheets"
+	SinkJSONL  = "jsonl"
+	SinkSQLite = "sqlite"
+)
+
+// EventSink stores analytics events. ctx bounds any waiting the sink does,
+// such as backing off from a rate limit, so shutdown is not held up.
+type EventSink interface {
+	WriteEvent(ctx context.Context, e Event) error
+	Close() error
+}
+
+// ErrRejected marks an error for events the sink will never accept, such as
+// one it cannot encode. The spool skips a rejected event instead of retrying
+// it; every other error is retried until it succeeds.
+var ErrRejected = errors.New("event rejected by sink")
+
+func rejected(err error) error { return fmt.Errorf("%w: %w", ErrRejected, err) }
+
+// joinSinkErrors reports the events as rejected only if every sink rejected
+// them; otherwise the sinks that may still accept them are retried.
+func joinSinkErrors(errs []error) error {
+	var retry []error
+	for _, err := range errs {
+		if !errors.Is(err, ErrRejected) {
+			retry = append(retry, err)
+		}
+	}
+	if len(retry) > 0 {
+		return errors.Join(retry...)
+	}
+	return errors.Join(errs...)
+}
+
+// Flusher is implemented by sinks that buffer events. Events passed to
+// WriteEvent are only durable once Flush returns nil. After an error from
+// either, the spool sends every event since the last successful Flush again,
+// so the sink must skip the ones it has already stored or still buffers.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Committer is implemented by sinks that remember events across resends so
+// they can skip them. The spool calls Committed once every event it has sent
+// is committed, after which none of them will be sent again.
+type Committer interface {
+	Committed()
+}
+
+// analyticsSinkNames returns the configured sinks, defaulting to the
+// analytics spreadsheet in prod and a local JSON Lines file otherwise.
+func analyticsSinkNames(cfg config.Config) []string {
+	if cfg.AnalyticsSinks == "" {
+		if cfg.Mode == config.ModeProd {
+			if cfg.AnalyticsSheetID == "" {
+				return nil
+			}
+			return []string{SinkSheets}
+		}
+		return []string{SinkJSONL}
+	}
+	var names []string
+	for _, name := range strings.Split(cfg.AnalyticsSinks, ",") {
+		if name = strings.TrimSpace(name); name != "" && name != "none" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func newEventSink(cfg config.Config, names []string, service *sheets.Service) (EventSink, error) {
+	var sinks []EventSink
+	for _, name := range names {
+		var sink EventSink
+		var err error
+		switch name {
+		case SinkSheets:
+			if cfg.AnalyticsSheetID == "" {
+				err = fmt.Errorf("ANALYTICS_SHEET_ID is required")
+				break
+			}
+			var opts SheetsSinkOptions
+			if opts, err = sheetsSinkOptions(cfg); err == nil {
+				sink = NewSheetsSink(service, cfg.AnalyticsSheetID, opts)
+			}
+		case SinkJSONL:
+			sink, err = NewJSONLSink(dataPath(cfg, cfg.AnalyticsJSONLPath, "events.jsonl"))
+		case SinkSQLite:
+			sink, err = NewSQLiteSink(dataPath(cfg, cfg.AnalyticsSQLitePath, "events.db"))
+		default:
+			err = fmt.Errorf("unknown sink")
+		}
+		if err != nil {
+			NewMultiSink(sinks...).Close()
+			return nil, fmt.Errorf("analytics sink %q: %w", name, err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	switch len(sinks) {
+	case 0:
+		return nil, nil
+	case 1:
+		return sinks[0], nil
+	}
+	return NewMultiSink(sinks...), nil
+}
+
+func dataPath(cfg config.Config, path, name string) string {
+	if path != "" {
+		return path
+	}
+	return filepath.Join(cfg.DataDir, name)
+}
+
+// MultiSink fans every event out to all of its sinks. A failing sink does
+// not stop the others from receiving the event. When the spool resends a
+// batch because one sink failed, the sinks that already took an event are
+// skipped, so they do not store it twice.
+type MultiSink struct {
+	sinks []EventSink
+
+	mu sync.Mutex
+	// done holds, per sink, the events sent since the spool last caught up
+	// that the sink has stored or rejected. buffered holds those a batching sink
+	// has accepted but not yet flushed. Events are keyed by their JSON.
+	done     []map[string]bool
+	buffered []map[string]bool
+}
+
+func NewMultiSink(sinks ...EventSink) *MultiSink {
+	m := &MultiSink{sinks: sinks}
+	m.Committed()
+	return m
+}
+
+func (m *MultiSink) Committed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sinks {
+		if c, ok := s.(Committer); ok {
+			c.Committed()
+		}
+	}
+	m.done = make([]map[string]bool, len(m.sinks))
+	m.buffered = make([]map[string]bool, len(m.sinks))
+	for i := range m.sinks {
+		m.done[i] = make(map[string]bool)
+		m.buffered[i] = make(map[string]bool)
+	}
+}
+
+func (m *MultiSink) WriteEvent(ctx context.Context, e Event) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return rejected(err)
+	}
+	key := string(raw)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for i, s := range m.sinks {
+		if m.done[i][key] || m.buffered[i][key] {
+			continue
+		}
+		err := s.WriteEvent(ctx, e)
+		_, batched := s.(Flusher)
+		switch {
+		case err != nil && !errors.Is(err, ErrRejected):
+			errs = append(errs, err)
+		case err != nil:
+			errs = append(errs, err)
+			m.done[i][key] = true
+		case batched:
+			m.buffered[i][key] = true
+		default:
+			m.done[i][key] = true
+		}
+	}
+	return joinSinkErrors(errs)
+}
+
+func (m *MultiSink) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for i, s := range m.sinks {
+		f, ok := s.(Flusher)
+		if !ok {
+			continue
+		}
+		if err := f.Flush(ctx); err != nil {
+			errs = append(errs, err)
+			m.buffered[i] = make(map[string]bool)
+			continue
+		}
+		for key := range m.buffered[i] {
+			m.done[i][key] = true
+		}
+		m.buffered[i] = make(map[string]bool)
+	}
+	return joinSinkErrors(errs)
+}
+
+func (m *MultiSink) Close() error {
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type JSONLSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func NewJSONLSink(path string) (*JSONLSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLSink{f: f}, nil
+}
+
+func (s *JSONLSink) WriteEvent(_ context.Context, e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return rejected(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	game_id    TEXT NOT NULL,
+	player_id  TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	timestamp  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_game ON events (game_id, event_type);
+CREATE INDEX IF NOT EXISTS events_player ON events (player_id);
+`
+
+type SQLiteSink struct {
+	db     *sql.DB
+	insert *sql.Stmt
+}
+
+func NewSQLiteSink(path string) (*SQLiteSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	insert, err := db.Prepare(`INSERT INTO events (game_id, player_id, event_type, data, timestamp) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteSink{db: db, insert: insert}, nil
+}
+
+func (s *SQLiteSink) WriteEvent(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return rejected(err)
+	}
+	_, err = s.insert.ExecContext(ctx, e.GameID, e.PlayerID, e.EventType, string(data), e.Timestamp.UTC().Format(time.RFC3339Nano))
+	return err
+}
+
+func (s *SQLiteSink) Close() error {
+	s.insert.Close()
+	return s.db.Close()
+}
@@ -0,0 +1,298 @@
+package game
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+	"gopkg.in/yaml.v3"
+
+	"references/internal/config"
+)
+
+const (
+	sheetsPuzzleTab   = "Sheet1"
+	sheetsPuzzleRange = sheetsPuzzleTab + "!A2:P"
+)
+
+const (
+	SourceStatic = "static"
+	SourceSheets = "sheets"
+	SourceCSV    = "csv"
+	SourceJSON   = "json"
+	SourceYAML   = "yaml"
+	SourceDir    = "dir"
+)
+
+type PuzzleSource interface {
+	Puzzles() ([]*WordData, error)
+}
+
+func NewPuzzleSource(cfg config.Config, sheet *Sheet) (PuzzleSource, error) {
+	kind := cfg.PuzzleSource
+	if kind == "" {
+		kind = SourceStatic
+		if cfg.Mode == config.ModeProd {
+			kind = SourceSheets
+		}
+	}
+
+	switch kind {
+	case SourceStatic:
+		return StaticSource{}, nil
+	case SourceSheets:
+		if sheet.service == nil {
+			return nil, fmt.Errorf("puzzle source %q needs Google credentials", kind)
+		}
+		return &SheetsSource{service: sheet.service, sheetID: cfg.WordSheetID, readRange: sheetsPuzzleRange}, nil
+	case SourceCSV:
+		return CSVFileSource{Path: cfg.PuzzlePath}, nil
+	case SourceJSON, SourceYAML:
+		return FileSource{Path: cfg.PuzzlePath, Format: kind}, nil
+	case SourceDir:
+		return DirSource{Dir: cfg.PuzzlePath}, nil
+	}
+	return nil, fmt.Errorf("unknown puzzle source %q", kind)
+}
+
+type StaticSource struct{}
+
+func (StaticSource) Puzzles() ([]*WordData, error) {
+	return readCSVPuzzles(strings.NewReader(staticCSV), "static data")
+}
+
+type SheetsSource struct {
+	service   *sheets.Service
+	sheetID   string
+	readRange string
+}
+
+func (s *SheetsSource) Puzzles() ([]*WordData, error) {
+	resp, err := s.service.Spreadsheets.Values.Get(s.sheetID, s.readRange).Do()
+	if err != nil {
+		return nil, fmt.Errorf("read prod sheet: %w", err)
+	}
+	if len(resp.Values) == 0 {
+		return nil, fmt.Errorf("no rows in prod sheet")
+	}
+	return parseRows(resp.Values, "prod sheet", 2)
+}
+
+type CSVFileSource struct {
+	Path string
+}
+
+func (s CSVFileSource) Puzzles() ([]*WordData, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open puzzle csv: %w", err)
+	}
+	defer f.Close()
+	return readCSVPuzzles(f, s.Path)
+}
+
+type FileSource struct {
+	Path   string
+	Format string
+}
+
+func (s FileSource) Puzzles() ([]*WordData, error) {
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read puzzle file: %w", err)
+	}
+	var files []PuzzleFile
+	if err := unmarshalPuzzles(raw, s.Format, &files); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", s.Path, err)
+	}
+
+	var out []*WordData
+	for i, pf := range files {
+		data, err := pf.wordData()
+		if err != nil {
+			log.Printf("skipping puzzle %d in %s: %v", i, s.Path, err)
+			continue
+		}
+		data.StoreID = strconv.Itoa(i + 1)
+		out = append(out, data)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no puzzles in %s", s.Path)
+	}
+	return out, nil
+}
+
+// DirSource reads one puzzle per file, ordered by file name. Files may be
+// .json, .yaml/.yml or single-row .csv.
+type DirSource struct {
+	Dir string
+}
+
+func (s DirSource) Puzzles() ([]*WordData, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("read puzzle dir: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && puzzleFileFormat(e.Name()) != "" {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var out []*WordData
+	for _, name := range names {
+		data, err := readPuzzleFile(filepath.Join(s.Dir, name))
+		if err != nil {
+			log.Printf("skipping %s: %v", name, err)
+			continue
+		}
+		data.StoreID = name
+		out = append(out, data)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no puzzles in %s", s.Dir)
+	}
+	return out, nil
+}
+
+func puzzleFileFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return SourceJSON
+	case ".yaml", ".yml":
+		return SourceYAML
+	case ".csv":
+		return SourceCSV
+	}
+	return ""
+}
+
+func readPuzzleFile(path string) (*WordData, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := puzzleFileFormat(path)
+	if format == SourceCSV {
+		rows, err := readCSVPuzzles(strings.NewReader(string(raw)), path)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) != 1 {
+			return nil, fmt.Errorf("expected one puzzle, found %d", len(rows))
+		}
+		return rows[0], nil
+	}
+
+	var pf PuzzleFile
+	if err := unmarshalPuzzles(raw, format, &pf); err != nil {
+		return nil, err
+	}
+	return pf.wordData()
+}
+
+func unmarshalPuzzles(raw []byte, format string, v interface{}) error {
+	if format == SourceYAML {
+		return yaml.Unmarshal(raw, v)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func readCSVPuzzles(r io.Reader, name string) ([]*WordData, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if len(records) > 0 && len(records[0]) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "answer") {
+		records = records[1:]
+	}
+	rows := make([][]interface{}, len(records))
+	for i, rec := range records {
+		rows[i] = interfaceSlice(rec)
+	}
+	return parseRows(rows, name, 1)
+}
+
+// parseRows numbers the rows from firstRow, which is also their StoreID.
+func parseRows(rows [][]interface{}, name string, firstRow int) ([]*WordData, error) {
+	var out []*WordData
+	for i, row := range rows {
+		data, err := parseWordData(row)
+		if err != nil {
+			log.Printf("skipping row %d in %s: %v", firstRow+i, name, err)
+			continue
+		}
+		data.StoreID = strconv.Itoa(firstRow + i)
+		out = append(out, data)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no valid puzzles in %s", name)
+	}
+	return out, nil
+}
+
+type PuzzleFile struct {
+	Answer     string           `json:"answer" yaml:"answer"`
+	Date       string           `json:"date,omitempty" yaml:"date,omitempty"`
+	Alternates []string         `json:"alternates,omitempty" yaml:"alternates,omitempty"`
+	Feedback   string           `json:"feedback,omitempty" yaml:"feedback,omitempty"`
+	Categories []PuzzleCategory `json:"categories" yaml:"categories"`
+}
+
+type PuzzleCategory struct {
+	Name  string `json:"name" yaml:"name"`
+	Hint  string `json:"hint" yaml:"hint"`
+	Emoji string `json:"emoji" yaml:"emoji"`
+}
+
+func (pf PuzzleFile) wordData() (*WordData, error) {
+	if strings.TrimSpace(pf.Answer) == "" {
+		return nil, fmt.Errorf("puzzle has no answer")
+	}
+	return parseWordData(pf.row())
+}
+
+// row lays the puzzle out in the spreadsheet's column order: answer, then
+// name/hint/emoji triples, then publish date, accepted alternates and
+// feedback mode.
+func (pf PuzzleFile) row() []interface{} {
+	row := []interface{}{pf.Answer}
+	for _, c := range pf.Categories {
+		row = append(row, c.Name, c.Hint, c.Emoji)
+	}
+	for len(row) < publishDateColumn {
+		row = append(row, "")
+	}
+	return append(row[:publishDateColumn], pf.Date, strings.Join(pf.Alternates, "; "), pf.Feedback)
+}
+
+// puzzleFileFromRow is the inverse of row. It keeps incomplete categories so
+// editors can see and fix them.
+func puzzleFileFromRow(row []interface{}) PuzzleFile {
+	cell := func(i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(fmt.Sprint(row[i]))
+		}
+		return ""
+	}
+	pf := PuzzleFile{Answer: cell(0), Date: cell(publishDateColumn), Alternates: splitAlternates(cell(alternatesColumn)), Feedback: cell(feedbackColumn)}
+	for i := 1; i < publishDateColumn; i += 3 {
+		c := PuzzleCategory{Name: cell(i), Hint: cell(i + 1), Emoji: cell(i + 2)}
+		if c != (PuzzleCategory{}) {
+			pf.Categories = append(pf.Categories, c)
+		}
+	}
+	return pf
+}
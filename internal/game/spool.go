@@ -0,0 +1,439 @@
+package game
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	spoolFileName     = "events.log"
+	spoolOffsetName   = "events.offset"
+	spoolBatchSize    = 100
+	spoolPollInterval = time.Second
+	spoolFlushEvery   = 5 * time.Second
+	shipBaseBackoff   = time.Second
+	shipMaxBackoff    = time.Minute
+)
+
+type SpoolCounters struct {
+	Queued  int64 `json:"queued"`
+	Shipped int64 `json:"shipped"`
+	Dropped int64 `json:"dropped"`
+	Pending int64 `json:"pending"`
+}
+
+// Spool is an append-only event log on local disk. LogEvent appends to it and
+// a shipper replays it to the sink, committing the read offset only once the
+// sink has accepted the events. Delivery is at-least-once.
+type Spool struct {
+	mu     sync.Mutex
+	dir    string
+	f      *os.File
+	offset int64
+	notify chan struct{}
+
+	// Shipper state. cursor is how far events have been handed to the sink;
+	// for a batching sink it runs ahead of offset until the next flush.
+	// sentUntil is the furthest it has ever run, which a rewind leaves as is.
+	cursor        int64
+	uncommitted   int64
+	sentUntil     int64
+	lastFlush     time.Time
+	failures      int
+	retryAt       time.Time
+	flushInterval time.Duration
+	// isolating is set after a batching sink rejects a batch. The events up
+	// to isolateUntil are then resent one at a time to find the bad ones.
+	isolating    bool
+	isolateUntil int64
+
+	queued  atomic.Int64
+	shipped atomic.Int64
+	dropped atomic.Int64
+	pending atomic.Int64
+}
+
+func OpenSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, spoolFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spool{dir: dir, f: f, notify: make(chan struct{}, 1), flushInterval: spoolFlushEvery, lastFlush: time.Now()}
+
+	raw, err := os.ReadFile(filepath.Join(dir, spoolOffsetName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		f.Close()
+		return nil, err
+	}
+	if len(raw) > 0 {
+		if s.offset, err = strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("corrupt spool offset: %w", err)
+		}
+	}
+
+	s.cursor = s.offset
+	backlog, err := s.countBacklog()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.pending.Store(backlog)
+	if backlog > 0 {
+		log.Printf("analytics spool: %d events waiting from a previous run", backlog)
+	}
+	return s, nil
+}
+
+func (s *Spool) countBacklog() (int64, error) {
+	r, err := os.Open(s.f.Name())
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	if _, err := r.Seek(s.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var n int64
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		n++
+	}
+	return n, sc.Err()
+}
+
+func (s *Spool) Append(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		s.dropped.Add(1)
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	_, err = s.f.Write(line)
+	s.mu.Unlock()
+	if err != nil {
+		s.dropped.Add(1)
+		return err
+	}
+	s.queued.Add(1)
+	s.pending.Add(1)
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *Spool) Counters() SpoolCounters {
+	return SpoolCounters{
+		Queued:  s.queued.Load(),
+		Shipped: s.shipped.Load(),
+		Dropped: s.dropped.Load(),
+		Pending: s.pending.Load(),
+	}
+}
+
+type spoolRecord struct {
+	event Event
+	end   int64
+}
+
+// readBatch returns up to max events after the cursor, and the offset just
+// past the last complete line read.
+func (s *Spool) readBatch(max int) ([]spoolRecord, int64, error) {
+	start := s.cursor
+	r, err := os.Open(s.f.Name())
+	if err != nil {
+		return nil, start, err
+	}
+	defer r.Close()
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, start, err
+	}
+
+	br := bufio.NewReader(r)
+	next := start
+	var records []spoolRecord
+	for len(records) < max {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return records, next, err
+		}
+		next += int64(len(line))
+
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			log.Printf("analytics spool: dropping corrupt record at offset %d: %v", next-int64(len(line)), err)
+			s.dropped.Add(1)
+			s.pending.Add(-1)
+			continue
+		}
+		records = append(records, spoolRecord{event: e, end: next})
+	}
+	return records, next, nil
+}
+
+// commit records offset as acknowledged. Once the shipper has caught up with
+// the writer the log is truncated so it does not grow without bound.
+func (s *Spool) commit(offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = offset
+	s.cursor = offset
+
+	info, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == offset {
+		if err := s.f.Truncate(0); err != nil {
+			return err
+		}
+		s.offset = 0
+		s.cursor = 0
+	}
+	return writeFileAtomic(filepath.Join(s.dir, spoolOffsetName), []byte(strconv.FormatInt(s.offset, 10)))
+}
+
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// Ship replays the spool to sink until ctx is cancelled; call Drain after it
+// returns to send what is left. A sink that
+// implements Flusher is flushed every flushInterval, and the offset is only
+// committed after a successful flush.
+func (s *Spool) Ship(ctx context.Context, sink EventSink) {
+	ticker := time.NewTicker(spoolPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.drain(ctx, sink, false); err != nil && ctx.Err() == nil {
+			log.Printf("analytics spool: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain ships and flushes everything in the spool, retrying failures until
+// the spool is empty or ctx is done.
+func (s *Spool) Drain(ctx context.Context, sink EventSink) error {
+	for {
+		err := s.drain(ctx, sink, true)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("analytics spool: drain: %v", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoffDelay(shipBaseBackoff, shipMaxBackoff, s.failures)):
+		}
+	}
+}
+
+func (s *Spool) drain(ctx context.Context, sink EventSink, final bool) error {
+	if !final && time.Now().Before(s.retryAt) {
+		return nil
+	}
+	flusher, batched := sink.(Flusher)
+	if batched && s.isolating {
+		if err := s.isolate(ctx, sink, flusher); err != nil {
+			return err
+		}
+	}
+	for {
+		records, next, err := s.readBatch(spoolBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			if !batched {
+				if err := s.shipOne(ctx, sink, rec.event); err != nil {
+					return err
+				}
+				continue
+			}
+			s.sentUntil = max(s.sentUntil, rec.end)
+			if err := sink.WriteEvent(ctx, rec.event); err != nil {
+				return s.fail(rec.end, err)
+			}
+			s.uncommitted++
+		}
+		if !batched {
+			if next != s.cursor {
+				if err := s.commit(next); err != nil {
+					return err
+				}
+			}
+		} else {
+			s.cursor = next
+		}
+		if len(records) < spoolBatchSize {
+			break
+		}
+	}
+	if !batched || s.uncommitted == 0 {
+		if batched && s.cursor != s.offset {
+			return s.commitSent(sink, s.cursor)
+		}
+		return nil
+	}
+	if !final && time.Since(s.lastFlush) < s.flushInterval {
+		return nil
+	}
+
+	if err := flusher.Flush(ctx); err != nil {
+		return s.fail(s.cursor, err)
+	}
+	s.lastFlush = time.Now()
+	s.shipped.Add(s.uncommitted)
+	s.pending.Add(-s.uncommitted)
+	s.uncommitted = 0
+	s.failures = 0
+	return s.commitSent(sink, s.cursor)
+}
+
+// commitSent commits offset for a batching sink and tells the sink once
+// every event it was sent is committed.
+func (s *Spool) commitSent(sink EventSink, offset int64) error {
+	caughtUp := offset >= s.sentUntil
+	if err := s.commit(offset); err != nil {
+		return err
+	}
+	if caughtUp {
+		s.sentUntil = s.offset
+		if c, ok := sink.(Committer); ok {
+			c.Committed()
+		}
+	}
+	return nil
+}
+
+// fail rewinds a batching sink to the last committed offset so the events
+// are sent again. Errors that may clear up are retried with capped backoff
+// for as long as they last; a rejected batch is resent one event at a time
+// so only the events the sink rejects are skipped.
+func (s *Spool) fail(end int64, err error) error {
+	s.cursor = s.offset
+	s.uncommitted = 0
+	if errors.Is(err, ErrRejected) {
+		s.isolating = true
+		s.isolateUntil = end
+		return fmt.Errorf("batch rejected, resending its events one at a time: %w", err)
+	}
+	return s.retryLater(err)
+}
+
+func (s *Spool) retryLater(err error) error {
+	s.failures++
+	s.retryAt = time.Now().Add(backoffDelay(shipBaseBackoff, shipMaxBackoff, s.failures))
+	return fmt.Errorf("ship failed (attempt %d), retrying: %w", s.failures, err)
+}
+
+// isolate ships and flushes the events of a rejected batch one at a time,
+// skipping those the sink rejects on their own.
+func (s *Spool) isolate(ctx context.Context, sink EventSink, flusher Flusher) error {
+	for s.offset < s.isolateUntil {
+		records, _, err := s.readBatch(1)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			break
+		}
+		rec := records[0]
+		s.sentUntil = max(s.sentUntil, rec.end)
+		err = sink.WriteEvent(ctx, rec.event)
+		if err == nil {
+			err = flusher.Flush(ctx)
+		}
+		switch {
+		case err == nil:
+			s.shipped.Add(1)
+		case errors.Is(err, ErrRejected):
+			log.Printf("analytics spool: skipping %s event for game %s: %v", rec.event.EventType, rec.event.GameID, err)
+			s.dropped.Add(1)
+		default:
+			return s.retryLater(err)
+		}
+		s.pending.Add(-1)
+		s.failures = 0
+		if err := s.commitSent(sink, rec.end); err != nil {
+			return err
+		}
+	}
+	s.isolating = false
+	return nil
+}
+
+// shipOne retries a failing event with capped exponential backoff until the
+// sink accepts it. Only an event the sink rejects outright is skipped.
+func (s *Spool) shipOne(ctx context.Context, sink EventSink, e Event) error {
+	for attempt := 1; ; attempt++ {
+		err := sink.WriteEvent(ctx, e)
+		if err == nil {
+			s.shipped.Add(1)
+			s.pending.Add(-1)
+			return nil
+		}
+		if errors.Is(err, ErrRejected) {
+			log.Printf("analytics spool: skipping %s event for game %s: %v", e.EventType, e.GameID, err)
+			s.dropped.Add(1)
+			s.pending.Add(-1)
+			return nil
+		}
+		backoff := backoffDelay(shipBaseBackoff, shipMaxBackoff, attempt)
+		log.Printf("analytics spool: ship failed (attempt %d), retrying in %s: %v", attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// backoffDelay is base doubled per attempt, capped at max, with up to 50%
+// random jitter so retrying writers do not move in lockstep.
+func backoffDelay(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
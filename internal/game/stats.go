@@ -0,0 +1,220 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	statsSnapshotInterval = 30 * time.Second
+	statsRetention        = 90 * 24 * time.Hour
+)
+
+type playerRecord struct {
+	Guesses   int       `json:"guesses"`
+	Hints     int       `json:"hints"`
+	Solved    bool      `json:"solved"`
+	SolvedAt  time.Time `json:"solvedAt,omitempty"`
+	FirstSeen time.Time `json:"firstSeen"`
+	// Archive is set for players who started the game after its day, who
+	// are left off the leaderboard.
+	Archive bool `json:"archive,omitempty"`
+}
+
+type gameStats struct {
+	Players   map[string]*playerRecord `json:"players"`
+	Solvers   []string                 `json:"solvers"`
+	LastEvent time.Time                `json:"lastEvent"`
+}
+
+// StatsAggregator keeps per-game player counts, solve order, guess
+// distribution and hint usage, built from the analytics event stream.
+type StatsAggregator struct {
+	mu    sync.Mutex
+	games map[string]*gameStats
+	path  string
+	dirty bool
+}
+
+func NewStatsAggregator(path string) (*StatsAggregator, error) {
+	a := &StatsAggregator{games: make(map[string]*gameStats), path: path}
+	if path == "" {
+		return a, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read stats snapshot: %w", err)
+	}
+	if err := json.Unmarshal(raw, &a.games); err != nil {
+		return nil, fmt.Errorf("decode stats snapshot: %w", err)
+	}
+	for _, gs := range a.games {
+		if gs.Players == nil {
+			gs.Players = make(map[string]*playerRecord)
+		}
+	}
+	return a, nil
+}
+
+// Record applies an event. archive marks events for a game other than the
+// live one.
+func (a *StatsAggregator) Record(e Event, archive bool) {
+	if e.PlayerID == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	gs, ok := a.games[e.GameID]
+	if !ok {
+		gs = &gameStats{Players: make(map[string]*playerRecord)}
+		a.games[e.GameID] = gs
+	}
+	rec, ok := gs.Players[e.PlayerID]
+	if !ok {
+		rec = &playerRecord{FirstSeen: e.Timestamp, Archive: archive}
+		gs.Players[e.PlayerID] = rec
+	}
+	if e.Timestamp.After(gs.LastEvent) {
+		gs.LastEvent = e.Timestamp
+	}
+
+	switch e.EventType {
+	case "guess":
+		if rec.finished() {
+			break
+		}
+		rec.Guesses++
+		if e.Data["correct"] == "true" {
+			rec.Solved = true
+			rec.SolvedAt = e.Timestamp
+			gs.Solvers = append(gs.Solvers, e.PlayerID)
+		}
+	case "hint":
+		rec.Hints++
+	}
+	a.dirty = true
+}
+
+func (rec *playerRecord) finished() bool {
+	return rec.Solved || rec.Guesses >= MaxGuesses
+}
+
+func (a *StatsAggregator) PlayerStats(gameID, playerID string) (*PlayerStats, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	gs, ok := a.games[gameID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGame, gameID)
+	}
+	player, ok := gs.Players[playerID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPlayer, playerID)
+	}
+
+	stats := &PlayerStats{
+		TotalPlayers:      len(gs.Players),
+		PlayersSolved:     len(gs.Solvers),
+		Solved:            player.Solved,
+		GuessesUsed:       player.Guesses,
+		HintsUsed:         player.Hints,
+		GuessDistribution: make([]int, MaxGuesses),
+		HintDistribution:  make(map[int]int),
+	}
+	for _, rec := range gs.Players {
+		if rec.Solved && rec.Guesses >= 1 && rec.Guesses <= MaxGuesses {
+			stats.GuessDistribution[rec.Guesses-1]++
+		}
+		stats.HintDistribution[rec.Hints]++
+	}
+	if player.Solved {
+		for i, id := range gs.Solvers {
+			if id == playerID {
+				stats.PlayerRank = i + 1
+				break
+			}
+		}
+		stats.SolveTime = player.SolvedAt.Format(time.RFC3339)
+		stats.Percentile = 100 * float64(stats.PlayersSolved-stats.PlayerRank) / float64(stats.PlayersSolved)
+	}
+	return stats, nil
+}
+
+// Run writes a snapshot whenever stats have changed, and once more when ctx
+// is cancelled.
+func (a *StatsAggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(statsSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := a.Snapshot(); err != nil {
+				log.Printf("stats snapshot failed: %v", err)
+			}
+			return
+		case <-ticker.C:
+			a.prune(time.Now().Add(-statsRetention))
+			if err := a.Snapshot(); err != nil {
+				log.Printf("stats snapshot failed: %v", err)
+			}
+		}
+	}
+}
+
+func (a *StatsAggregator) prune(before time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, gs := range a.games {
+		if gs.LastEvent.Before(before) {
+			delete(a.games, id)
+			a.dirty = true
+		}
+	}
+}
+
+func (a *StatsAggregator) Snapshot() error {
+	if a.path == "" {
+		return nil
+	}
+	a.mu.Lock()
+	if !a.dirty {
+		a.mu.Unlock()
+		return nil
+	}
+	raw, err := json.Marshal(a.games)
+	a.dirty = false
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(a.path, raw); err != nil {
+		a.mu.Lock()
+		a.dirty = true
+		a.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, raw []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,364 @@
+package game
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownPuzzle = errors.New("unknown puzzle")
+
+// PuzzleLister returns every stored puzzle as written, including ones the
+// game would skip as invalid.
+type PuzzleLister interface {
+	List() ([]StoredPuzzle, error)
+}
+
+// PuzzleStore is implemented by puzzle sources that can be edited. Save
+// creates a puzzle when id is empty.
+type PuzzleStore interface {
+	PuzzleSource
+	PuzzleLister
+	Save(id string, pf PuzzleFile) (string, error)
+}
+
+type StoredPuzzle struct {
+	ID string
+	// Columns is the number of cells in the source row, or 0 for puzzles
+	// stored as JSON or YAML.
+	Columns int
+	// ReadErr is why the puzzle could not be read, in which case PuzzleFile
+	// is empty.
+	ReadErr error
+	PuzzleFile
+}
+
+// List identifies puzzles by their 1-based row.
+func (StaticSource) List() ([]StoredPuzzle, error) {
+	cr := csv.NewReader(strings.NewReader(staticCSV))
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return csvStoredPuzzles(records), nil
+}
+
+func csvStoredPuzzles(records [][]string) []StoredPuzzle {
+	out := make([]StoredPuzzle, len(records))
+	for i, rec := range records {
+		out[i] = StoredPuzzle{ID: strconv.Itoa(i + 1), Columns: len(rec), PuzzleFile: puzzleFileFromRow(interfaceSlice(rec))}
+	}
+	return out
+}
+
+func (s CSVFileSource) readRecords() (header []string, records [][]string, err error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open puzzle csv: %w", err)
+	}
+	defer f.Close()
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	records, err = cr.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read %s: %w", s.Path, err)
+	}
+	if len(records) > 0 && len(records[0]) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "answer") {
+		return records[0], records[1:], nil
+	}
+	return nil, records, nil
+}
+
+// List identifies puzzles by their 1-based data row, not counting a header.
+func (s CSVFileSource) List() ([]StoredPuzzle, error) {
+	_, records, err := s.readRecords()
+	if err != nil {
+		return nil, err
+	}
+	return csvStoredPuzzles(records), nil
+}
+
+func (s CSVFileSource) Save(id string, pf PuzzleFile) (string, error) {
+	header, records, err := s.readRecords()
+	if err != nil {
+		return "", err
+	}
+	i, err := storeIndex(id, len(records))
+	if err != nil {
+		return "", err
+	}
+	if i == len(records) {
+		records = append(records, nil)
+	}
+	records[i] = rowStrings(pf.row())
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	if header != nil {
+		cw.Write(header)
+	}
+	cw.WriteAll(records)
+	if err := cw.Error(); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(s.Path, buf.Bytes()); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(i + 1), nil
+}
+
+// List identifies puzzles by their 1-based position in the file.
+func (s FileSource) List() ([]StoredPuzzle, error) {
+	files, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StoredPuzzle, len(files))
+	for i, pf := range files {
+		out[i] = StoredPuzzle{ID: strconv.Itoa(i + 1), PuzzleFile: pf}
+	}
+	return out, nil
+}
+
+func (s FileSource) readAll() ([]PuzzleFile, error) {
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read puzzle file: %w", err)
+	}
+	var files []PuzzleFile
+	if err := unmarshalPuzzles(raw, s.Format, &files); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", s.Path, err)
+	}
+	return files, nil
+}
+
+func (s FileSource) Save(id string, pf PuzzleFile) (string, error) {
+	files, err := s.readAll()
+	if err != nil {
+		return "", err
+	}
+	i, err := storeIndex(id, len(files))
+	if err != nil {
+		return "", err
+	}
+	if i == len(files) {
+		files = append(files, PuzzleFile{})
+	}
+	files[i] = pf
+
+	raw, err := marshalPuzzles(files, s.Format)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(s.Path, raw); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(i + 1), nil
+}
+
+// List identifies puzzles by file name. A file that cannot be read is listed
+// with its ReadErr, so one bad file does not hide the rest.
+func (s DirSource) List() ([]StoredPuzzle, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("read puzzle dir: %w", err)
+	}
+	var out []StoredPuzzle
+	for _, e := range entries {
+		if e.IsDir() || puzzleFileFormat(e.Name()) == "" {
+			continue
+		}
+		sp, err := readRawPuzzleFile(filepath.Join(s.Dir, e.Name()))
+		if err != nil {
+			sp = StoredPuzzle{ReadErr: err}
+		}
+		sp.ID = e.Name()
+		out = append(out, sp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// Save writes an existing puzzle back in its own format. New puzzles are
+// written as JSON, named after their publish date or answer.
+func (s DirSource) Save(id string, pf PuzzleFile) (string, error) {
+	if id == "" {
+		name, err := s.newFileName(pf)
+		if err != nil {
+			return "", err
+		}
+		id = name
+	} else if filepath.Base(id) != id || puzzleFileFormat(id) == "" {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPuzzle, id)
+	} else if _, err := os.Stat(filepath.Join(s.Dir, id)); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPuzzle, id)
+	}
+
+	var raw []byte
+	var err error
+	if format := puzzleFileFormat(id); format == SourceCSV {
+		var buf bytes.Buffer
+		cw := csv.NewWriter(&buf)
+		cw.Write(rowStrings(pf.row()))
+		cw.Flush()
+		raw, err = buf.Bytes(), cw.Error()
+	} else {
+		raw, err = marshalPuzzles(pf, format)
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, writeFileAtomic(filepath.Join(s.Dir, id), raw)
+}
+
+var fileNameUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+func (s DirSource) newFileName(pf PuzzleFile) (string, error) {
+	base := pf.Date
+	if base == "" {
+		base = strings.Trim(fileNameUnsafe.ReplaceAllString(strings.ToLower(pf.Answer), "-"), "-")
+	}
+	if base == "" {
+		base = "puzzle"
+	}
+	for n := 1; n < 1000; n++ {
+		name := base + ".json"
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d.json", base, n)
+		}
+		if _, err := os.Stat(filepath.Join(s.Dir, name)); errors.Is(err, os.ErrNotExist) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no free file name for %q", base)
+}
+
+func readRawPuzzleFile(path string) (StoredPuzzle, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return StoredPuzzle{}, err
+	}
+	format := puzzleFileFormat(path)
+	if format == SourceCSV {
+		cr := csv.NewReader(bytes.NewReader(raw))
+		cr.FieldsPerRecord = -1
+		records, err := cr.ReadAll()
+		if err != nil {
+			return StoredPuzzle{}, err
+		}
+		if len(records) > 0 && len(records[0]) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "answer") {
+			records = records[1:]
+		}
+		if len(records) == 0 {
+			return StoredPuzzle{}, nil
+		}
+		return StoredPuzzle{Columns: len(records[0]), PuzzleFile: puzzleFileFromRow(interfaceSlice(records[0]))}, nil
+	}
+	var sp StoredPuzzle
+	err = unmarshalPuzzles(raw, format, &sp.PuzzleFile)
+	return sp, err
+}
+
+// List identifies puzzles by their row number in the sheet.
+func (s *SheetsSource) List() ([]StoredPuzzle, error) {
+	resp, err := s.service.Spreadsheets.Values.Get(s.sheetID, s.readRange).Do()
+	if err != nil {
+		return nil, fmt.Errorf("read prod sheet: %w", err)
+	}
+	out := make([]StoredPuzzle, 0, len(resp.Values))
+	for i, row := range resp.Values {
+		if len(row) == 0 {
+			continue
+		}
+		out = append(out, StoredPuzzle{ID: strconv.Itoa(i + 2), Columns: len(row), PuzzleFile: puzzleFileFromRow(row)})
+	}
+	return out, nil
+}
+
+var sheetRowPattern = regexp.MustCompile(`![A-Z]+(\d+)`)
+
+func (s *SheetsSource) Save(id string, pf PuzzleFile) (string, error) {
+	values := &sheets.ValueRange{Values: [][]interface{}{pf.row()}}
+	if id == "" {
+		resp, err := s.service.Spreadsheets.Values.Append(s.sheetID, sheetsPuzzleTab+"!A:P", values).
+			ValueInputOption("RAW").InsertDataOption("INSERT_ROWS").Do()
+		if err != nil {
+			return "", fmt.Errorf("append puzzle: %w", err)
+		}
+		if resp.Updates != nil {
+			if m := sheetRowPattern.FindStringSubmatch(resp.Updates.UpdatedRange); m != nil {
+				return m[1], nil
+			}
+		}
+		return "", nil
+	}
+
+	row, err := strconv.Atoi(id)
+	if err != nil || row < 2 {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPuzzle, id)
+	}
+	rng := fmt.Sprintf("%s!A%d:P%d", sheetsPuzzleTab, row, row)
+	if _, err := s.service.Spreadsheets.Values.Update(s.sheetID, rng, values).ValueInputOption("RAW").Do(); err != nil {
+		return "", fmt.Errorf("update puzzle: %w", err)
+	}
+	return id, nil
+}
+
+// storeIndex maps a 1-based ID to a slice index; an empty ID means append.
+func storeIndex(id string, n int) (int, error) {
+	if id == "" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(id)
+	if err != nil || i < 1 || i > n {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownPuzzle, id)
+	}
+	return i - 1, nil
+}
+
+// yamlEscape matches the escapes yaml.v3 writes for characters outside the
+// BMP, such as most emoji, along with escaped backslashes so those are left
+// alone.
+var yamlEscape = regexp.MustCompile(`\\\\|\\U[0-9A-F]{8}|\\u[0-9A-F]{4}`)
+
+func marshalPuzzles(v interface{}, format string) ([]byte, error) {
+	if format == SourceYAML {
+		raw, err := yaml.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		// Keep emoji readable for people editing the file by hand.
+		return yamlEscape.ReplaceAllFunc(raw, func(esc []byte) []byte {
+			if len(esc) == 2 {
+				return esc
+			}
+			code, _ := strconv.ParseUint(string(esc[2:]), 16, 32)
+			return []byte(string(rune(code)))
+		}), nil
+	}
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(raw, '\n'), nil
+}
+
+func rowStrings(row []interface{}) []string {
+	out := make([]string, len(row))
+	for i, v := range row {
+		out[i] = fmt.Sprint(v)
+	}
+	return out
+}
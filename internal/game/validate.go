@@ -0,0 +1,211 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const CategoriesPerPuzzle = 4
+
+// Problem is one thing wrong with a puzzle. Field names the form field or
+// column it concerns, e.g. "answer" or "categories[2].hint".
+type Problem struct {
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Field == "" {
+		return p.Message
+	}
+	return p.Field + ": " + p.Message
+}
+
+// Validate reports problems with a single puzzle.
+func (pf PuzzleFile) Validate() []Problem {
+	var problems []Problem
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	answer := strings.TrimSpace(pf.Answer)
+	if answer == "" {
+		add("answer", "answer is required")
+	}
+	letters := 0
+	for _, slot := range answerSlots(answer) {
+		switch {
+		case slot.Letter && typeable(slot.Rune):
+			letters++
+		case slot.Letter || !strings.ContainsRune(answerSeparators, slot.Rune):
+			add("answer", "%q cannot be typed into the answer boxes", slot.Rune)
+		}
+	}
+	if answer != "" && letters == 0 {
+		add("answer", "answer has no letters")
+	}
+
+	for i, alt := range pf.Alternates {
+		field := fmt.Sprintf("alternates[%d]", i)
+		folded := foldAnswer(alt)
+		switch {
+		case folded == "":
+			add(field, "alternate has no letters")
+		case folded == foldAnswer(answer):
+			add(field, "alternate %q is the same as the answer", alt)
+		}
+		for _, r := range alt {
+			if isAnswerLetter(r) && !typeable(r) {
+				add(field, "%q cannot be typed into the answer boxes", r)
+			}
+		}
+	}
+
+	if pf.Feedback != "" {
+		if _, err := ParseFeedbackMode(pf.Feedback); err != nil {
+			add("feedback", "feedback must be none, reveal or letters")
+		}
+	}
+
+	if pf.Date != "" {
+		if _, err := time.Parse(gameIDLayout, pf.Date); err != nil {
+			add("date", "publish date must look like 2025-04-10")
+		}
+	}
+
+	if len(pf.Categories) != CategoriesPerPuzzle {
+		add("categories", "expected %d categories, got %d", CategoriesPerPuzzle, len(pf.Categories))
+	}
+	seen := make(map[string]bool)
+	for i, c := range pf.Categories {
+		field := fmt.Sprintf("categories[%d]", i)
+		name := strings.TrimSpace(c.Name)
+		if name == "" {
+			add(field+".name", "category name is required")
+		} else if seen[strings.ToLower(name)] {
+			add(field+".name", "category %q is used twice", name)
+		}
+		seen[strings.ToLower(name)] = true
+		if strings.TrimSpace(c.Hint) == "" {
+			add(field+".hint", "hint is required")
+		}
+		if emoji := strings.TrimSpace(c.Emoji); emoji == "" {
+			add(field+".emoji", "emoji is required")
+		} else if !isEmoji(emoji) {
+			add(field+".emoji", "%q is not an emoji", emoji)
+		}
+	}
+	return problems
+}
+
+// Problems returns the problems with sp on its own and against the other
+// puzzles in all. A puzzle that could not be read has only that problem.
+func (sp StoredPuzzle) Problems(all []StoredPuzzle) []Problem {
+	if sp.ReadErr != nil {
+		return []Problem{{Field: "file", Message: sp.ReadErr.Error()}}
+	}
+	return append(sp.Validate(), sp.Conflicts(sp.ID, all)...)
+}
+
+// Conflicts reports clashes between pf, stored as id, and the other puzzles
+// in the store: an answer or alternate another puzzle already accepts, as
+// guesses are compared, or a repeated publish date.
+func (pf PuzzleFile) Conflicts(id string, all []StoredPuzzle) []Problem {
+	var problems []Problem
+	for _, other := range all {
+		if other.ID == id {
+			continue
+		}
+		accepted := map[string]bool{foldAnswer(other.Answer): true}
+		for _, alt := range other.Alternates {
+			accepted[foldAnswer(alt)] = true
+		}
+		delete(accepted, "")
+		if accepted[foldAnswer(pf.Answer)] {
+			problems = append(problems, Problem{Field: "answer", Message: fmt.Sprintf("answer is already accepted by puzzle %s", other.ID)})
+		}
+		for i, alt := range pf.Alternates {
+			if accepted[foldAnswer(alt)] {
+				problems = append(problems, Problem{Field: fmt.Sprintf("alternates[%d]", i), Message: fmt.Sprintf("alternate %q is already accepted by puzzle %s", alt, other.ID)})
+			}
+		}
+		if pf.Date != "" && other.Date == pf.Date {
+			problems = append(problems, Problem{Field: "date", Message: fmt.Sprintf("puzzle %s is already scheduled for %s", other.ID, pf.Date)})
+		}
+	}
+	return problems
+}
+
+// isEmoji accepts a string made of emoji: pictographic symbols joined by
+// zero-width joiners, with variation selectors, skin tones, flags and
+// keycaps.
+func isEmoji(s string) bool {
+	keycap := strings.ContainsRune(s, '\u20e3')
+	symbols := 0
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.So, r):
+			symbols++
+		case r >= 0x1f3fb && r <= 0x1f3ff: // skin tone modifiers
+		case r == '\u200d', r == '\ufe0e', r == '\ufe0f', r == '\u20e3':
+		case r >= 0xe0020 && r <= 0xe007f: // tag sequences in subdivision flags
+		case keycap && (r == '#' || r == '*' || (r >= '0' && r <= '9')):
+			symbols++
+		default:
+			return false
+		}
+	}
+	return symbols > 0
+}
+
+// PuzzleProblem is a Problem found while checking a whole collection. ID is
+// empty for problems with the calendar rather than one puzzle.
+type PuzzleProblem struct {
+	ID string
+	Problem
+}
+
+func (p PuzzleProblem) String() string {
+	if p.ID == "" {
+		return p.Problem.String()
+	}
+	return p.ID + ": " + p.Problem.String()
+}
+
+// ValidateAll checks every puzzle on its own and against the others, and
+// reports days from from through to, or through the last scheduled puzzle if
+// later, that have no puzzle.
+func ValidateAll(all []StoredPuzzle, from, to time.Time) []PuzzleProblem {
+	var problems []PuzzleProblem
+	scheduled := make(map[string]bool)
+	var last time.Time
+	for _, sp := range all {
+		if sp.Columns > 0 && sp.Columns < publishDateColumn {
+			problems = append(problems, PuzzleProblem{sp.ID, Problem{Field: "columns", Message: fmt.Sprintf("row has %d columns, expected at least %d", sp.Columns, publishDateColumn)}})
+		}
+		for _, p := range sp.Problems(all) {
+			problems = append(problems, PuzzleProblem{sp.ID, p})
+		}
+		if date, err := time.Parse(gameIDLayout, sp.Date); err == nil {
+			scheduled[sp.Date] = true
+			if date.After(last) {
+				last = date
+			}
+		}
+	}
+
+	if len(scheduled) == 0 {
+		return problems
+	}
+	if last.After(to) {
+		to = last
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if !scheduled[d.Format(gameIDLayout)] {
+			problems = append(problems, PuzzleProblem{Problem: Problem{Field: "calendar", Message: "no puzzle scheduled for " + d.Format(gameIDLayout)}})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"references/internal/accounts"
+)
+
+func (h *Handlers) AccountHandler(w http.ResponseWriter, r *http.Request) {
+	if h.accounts == nil {
+		http.NotFound(w, r)
+		return
+	}
+	h.ensurePlayer(w, r)
+	acct, signedIn := h.accounts.FromRequest(r)
+	h.renderAccount(w, http.StatusOK, accountPage{
+		SignedIn: signedIn,
+		Account:  acct,
+		Sent:     r.URL.Query().Get("sent") != "",
+	})
+}
+
+func (h *Handlers) AccountLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if h.accounts == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	// Only the player ID this browser has proven it holds may be claimed.
+	playerID := h.playerID(r, "")
+	if playerID == "" {
+		h.renderAccount(w, http.StatusBadRequest, accountPage{Error: "We couldn't tell which player this browser is. Reload the page and try again."})
+		return
+	}
+	err := h.accounts.StartLogin(r.Context(), r.PostFormValue("email"), playerID)
+	switch {
+	case errors.Is(err, accounts.ErrInvalidEmail):
+		h.renderAccount(w, http.StatusBadRequest, accountPage{Error: "That doesn't look like an email address."})
+	case errors.Is(err, accounts.ErrTooSoon):
+		h.renderAccount(w, http.StatusTooManyRequests, accountPage{Error: "We just sent you a link. Check your inbox, or try again in a minute."})
+	case err != nil:
+		fmt.Printf("Error sending sign-in link: %v\n", err)
+		h.renderAccount(w, http.StatusBadGateway, accountPage{Error: "We couldn't send the email. Please try again later."})
+	default:
+		http.Redirect(w, r, "/account?sent=1", http.StatusSeeOther)
+	}
+}
+
+// AccountVerifyHandler signs the browser in from an emailed link and moves
+// the history, games in progress, display name and league memberships of the
+// browser's player ID to the account.
+func (h *Handlers) AccountVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if h.accounts == nil {
+		http.NotFound(w, r)
+		return
+	}
+	acct, merged, err := h.accounts.CompleteLogin(r.URL.Query().Get("token"))
+	if errors.Is(err, accounts.ErrInvalidLink) {
+		h.renderAccount(w, http.StatusBadRequest, accountPage{Error: "That sign-in link is invalid or has expired. Request a new one below."})
+		return
+	}
+	if errors.Is(err, accounts.ErrClaimed) {
+		h.renderAccount(w, http.StatusConflict, accountPage{Error: "The games on this browser already belong to another account. Sign in from a browser you play on."})
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error completing sign-in: %v\n", err)
+		h.renderAccount(w, http.StatusInternalServerError, accountPage{Error: "Something went wrong signing you in. Please try again."})
+		return
+	}
+	if merged != "" {
+		h.game.History.Merge(merged, acct.PlayerID)
+		h.game.Sessions.Merge(merged, acct.PlayerID)
+		if err := h.game.Names.Merge(merged, acct.PlayerID); err != nil {
+			fmt.Printf("Error moving display name: %v\n", err)
+		}
+		if err := h.leagues.ReplaceMember(merged, acct.PlayerID); err != nil {
+			fmt.Printf("Error moving league memberships: %v\n", err)
+		}
+	}
+	h.accounts.SetSession(w, acct)
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (h *Handlers) AccountLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if h.accounts == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	h.accounts.ClearSession(w)
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+type accountPage struct {
+	SignedIn bool
+	Account  accounts.Account
+	Sent     bool
+	Error    string
+}
+
+func (h *Handlers) renderAccount(w http.ResponseWriter, status int, data accountPage) {
+	tmpl, err := parseTemplate("web/templates/account.html")
+	if err != nil {
+		fmt.Printf("Error parsing account.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Printf("Error executing account.html: %v\n", err)
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"references/internal/game"
+	"references/internal/utils"
+)
+
+// RequireAdmin guards the admin pages with HTTP basic auth. The admin area
+// does not exist unless ADMIN_PASSWORD is set.
+func (h *Handlers) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := h.game.Cfg
+		if cfg.AdminPassword == "" {
+			http.NotFound(w, r)
+			return
+		}
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(cfg.AdminUser)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.AdminPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="references admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// Browsers replay basic auth credentials on cross-site form posts,
+		// so a post must show it came from this host.
+		if r.Method == http.MethodPost && !sameOrigin(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func (h *Handlers) puzzleStore() (game.PuzzleStore, bool) {
+	store, ok := h.game.Source.(game.PuzzleStore)
+	return store, ok
+}
+
+type adminPuzzle struct {
+	ID       string
+	Answer   string
+	Date     string
+	Problems []game.Problem
+}
+
+func (h *Handlers) AdminHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := parseTemplate("web/templates/admin.html")
+	if err != nil {
+		fmt.Printf("Error parsing admin.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	store, writable := h.puzzleStore()
+	var puzzles []adminPuzzle
+	if writable {
+		stored, err := store.List()
+		if err != nil {
+			fmt.Printf("Error listing puzzles: %v\n", err)
+			http.Error(w, "Could not read puzzles", http.StatusBadGateway)
+			return
+		}
+		for _, sp := range stored {
+			puzzles = append(puzzles, adminPuzzle{
+				ID:       sp.ID,
+				Answer:   sp.Answer,
+				Date:     sp.Date,
+				Problems: sp.Problems(stored),
+			})
+		}
+	}
+
+	data := struct {
+		Writable bool
+		Saved    string
+		Puzzles  []adminPuzzle
+	}{
+		Writable: writable,
+		Saved:    r.URL.Query().Get("saved"),
+		Puzzles:  puzzles,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Printf("Error executing admin.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handlers) AdminEditHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.puzzleStore()
+	if !ok {
+		http.Error(w, "The configured puzzle source is read-only", http.StatusConflict)
+		return
+	}
+
+	id := r.PathValue("id")
+	var pf game.PuzzleFile
+	var problems []game.Problem
+	if id != "" {
+		stored, err := store.List()
+		if err != nil {
+			fmt.Printf("Error listing puzzles: %v\n", err)
+			http.Error(w, "Could not read puzzles", http.StatusBadGateway)
+			return
+		}
+		found := false
+		for _, sp := range stored {
+			if sp.ID == id {
+				pf, found = sp.PuzzleFile, true
+				if sp.ReadErr != nil {
+					problems = sp.Problems(stored)
+				}
+				break
+			}
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	renderAdminForm(w, http.StatusOK, id, pf, problems)
+}
+
+func (h *Handlers) AdminSaveHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.puzzleStore()
+	if !ok {
+		http.Error(w, "The configured puzzle source is read-only", http.StatusConflict)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	pf := puzzleFromForm(r)
+	problems := pf.Validate()
+	stored, err := store.List()
+	if err != nil {
+		fmt.Printf("Error listing puzzles: %v\n", err)
+		http.Error(w, "Could not read puzzles", http.StatusBadGateway)
+		return
+	}
+	problems = append(problems, pf.Conflicts(id, stored)...)
+	if len(problems) > 0 {
+		renderAdminForm(w, http.StatusUnprocessableEntity, id, pf, problems)
+		return
+	}
+
+	savedID, err := store.Save(id, pf)
+	if errors.Is(err, game.ErrUnknownPuzzle) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error saving puzzle %q: %v\n", id, err)
+		http.Error(w, "Could not save puzzle", http.StatusBadGateway)
+		return
+	}
+	if err := h.game.PuzzleSaved(savedID, pf); err != nil {
+		fmt.Printf("Error reloading puzzles after save: %v\n", err)
+	}
+	http.Redirect(w, r, "/admin?saved="+url.QueryEscape(savedID), http.StatusSeeOther)
+}
+
+// AdminPreviewHandler renders the draft in the form with the real game page,
+// with guessing and hints switched off.
+func (h *Handlers) AdminPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	p, err := h.game.PreviewPuzzle(puzzleFromForm(r))
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "Nothing to preview yet: %v", err)
+		return
+	}
+	h.renderPuzzle(w, p, previewPage)
+}
+
+func puzzleFromForm(r *http.Request) game.PuzzleFile {
+	pf := game.PuzzleFile{
+		Answer:   strings.TrimSpace(r.PostFormValue("answer")),
+		Date:     strings.TrimSpace(r.PostFormValue("date")),
+		Feedback: r.PostFormValue("feedback"),
+	}
+	for _, alt := range strings.Split(r.PostFormValue("alternates"), ";") {
+		if alt = strings.TrimSpace(alt); alt != "" {
+			pf.Alternates = append(pf.Alternates, alt)
+		}
+	}
+	for i := 0; i < game.CategoriesPerPuzzle; i++ {
+		c := game.PuzzleCategory{
+			Name:  strings.TrimSpace(r.PostFormValue(fmt.Sprintf("name-%d", i))),
+			Hint:  strings.TrimSpace(r.PostFormValue(fmt.Sprintf("hint-%d", i))),
+			Emoji: strings.TrimSpace(r.PostFormValue(fmt.Sprintf("emoji-%d", i))),
+		}
+		if c != (game.PuzzleCategory{}) {
+			pf.Categories = append(pf.Categories, c)
+		}
+	}
+	return pf
+}
+
+func renderAdminForm(w http.ResponseWriter, status int, id string, pf game.PuzzleFile, problems []game.Problem) {
+	tmpl, err := parseTemplate("web/templates/admin-edit.html")
+	if err != nil {
+		fmt.Printf("Error parsing admin-edit.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	categories := make([]game.PuzzleCategory, game.CategoriesPerPuzzle)
+	copy(categories, pf.Categories)
+
+	action := "/admin/puzzles"
+	if id != "" {
+		action += "/" + url.PathEscape(id)
+	}
+
+	data := struct {
+		ID         string
+		Action     string
+		Answer     string
+		Date       string
+		Alternates string
+		Feedback   string
+		Categories []game.PuzzleCategory
+		Problems   []game.Problem
+	}{
+		ID:         id,
+		Action:     action,
+		Answer:     pf.Answer,
+		Date:       pf.Date,
+		Alternates: strings.Join(pf.Alternates, "; "),
+		Feedback:   pf.Feedback,
+		Categories: categories,
+		Problems:   problems,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Printf("Error executing admin-edit.html: %v\n", err)
+	}
+}
+
+func (h *Handlers) AdminFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.RespondJSON(w, http.StatusOK, h.game.Flags.All())
+}
+
+// AdminReloadFlagsHandler re-reads the flags file now rather than waiting
+// for the next periodic check.
+func (h *Handlers) AdminReloadFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.game.Flags.Reload(); err != nil {
+		fmt.Printf("Error reloading flags: %v\n", err)
+		utils.RespondErrorCode(w, http.StatusUnprocessableEntity, "invalid_flags", err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, h.game.Flags.All())
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"references/internal/game"
+	"references/internal/utils"
+)
+
+const (
+	apiTodayID      = "today"
+	maxAPIBodyBytes = 1 << 16
+)
+
+type puzzleMetadata struct {
+	GameID     string         `json:"gameId"`
+	GameNumber int            `json:"gameNumber"`
+	Date       string         `json:"date"`
+	MaskedWord string         `json:"maskedWord"`
+	WordLength int            `json:"wordLength"`
+	MaxGuesses int            `json:"maxGuesses"`
+	Categories []categoryInfo `json:"categories"`
+}
+
+type categoryInfo struct {
+	Name  string `json:"name"`
+	Emoji string `json:"emoji"`
+}
+
+type resultResponse struct {
+	GameID           string                `json:"gameId"`
+	Finished         bool                  `json:"finished"`
+	Solved           bool                  `json:"solved"`
+	GuessesUsed      int                   `json:"guessesUsed"`
+	RemainingGuesses int                   `json:"remainingGuesses"`
+	HintsUsed        int                   `json:"hintsUsed"`
+	HintsOpened      []string              `json:"hintsOpened"`
+	Feedback         [][]game.LetterResult `json:"feedback,omitempty"`
+	Word             string                `json:"word,omitempty"`
+}
+
+type apiGuessRequest struct {
+	PlayerID string `json:"playerId"`
+	Guess    string `json:"guess"`
+}
+
+type apiHintRequest struct {
+	PlayerID string `json:"playerId"`
+	Category string `json:"category"`
+}
+
+func (h *Handlers) apiPuzzle(w http.ResponseWriter, r *http.Request) (*game.Puzzle, bool) {
+	gameID := r.PathValue("gameId")
+	if gameID == apiTodayID {
+		p := h.game.Current()
+		if p.Missing {
+			utils.RespondErrorCode(w, http.StatusNotFound, "no_puzzle", "No puzzle is scheduled today")
+		}
+		return p, !p.Missing
+	}
+	p, ok := h.game.Puzzle(gameID)
+	if !ok {
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_game", "Unknown game")
+	}
+	return p, ok
+}
+
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		utils.RespondErrorCode(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only "+method+" method is allowed")
+		return false
+	}
+	return true
+}
+
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "invalid_body", "Request body must be a JSON object")
+		return false
+	}
+	return true
+}
+
+func (h *Handlers) APIPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	p, ok := h.apiPuzzle(w, r)
+	if !ok {
+		return
+	}
+
+	meta := puzzleMetadata{
+		GameID:     p.GameID,
+		GameNumber: h.game.GameNumber(p.Date),
+		Date:       p.GameID,
+		MaskedWord: p.GetMaskedWord(),
+		WordLength: p.LetterCount(),
+		MaxGuesses: game.MaxGuesses,
+	}
+	for _, c := range p.GetCategories() {
+		emoji, _ := p.GetEmoji(c)
+		meta.Categories = append(meta.Categories, categoryInfo{Name: c, Emoji: emoji})
+	}
+	utils.RespondJSON(w, http.StatusOK, meta)
+}
+
+func (h *Handlers) APIGuessHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	p, ok := h.apiPuzzle(w, r)
+	if !ok {
+		return
+	}
+	var req apiGuessRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Guess) == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_guess", "Guess cannot be empty")
+		return
+	}
+	req.PlayerID = h.playerID(r, req.PlayerID)
+	if req.PlayerID == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+
+	response, err := h.submitGuess(p, req.PlayerID, req.Guess)
+	if err != nil {
+		respondGameError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, response)
+}
+
+func (h *Handlers) APIHintHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	p, ok := h.apiPuzzle(w, r)
+	if !ok {
+		return
+	}
+	var req apiHintRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Category == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_category", "Category cannot be empty")
+		return
+	}
+	req.PlayerID = h.playerID(r, req.PlayerID)
+	if req.PlayerID == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+
+	response, err := h.revealHint(p, req.PlayerID, req.Category)
+	if err != nil {
+		respondGameError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, response)
+}
+
+func (h *Handlers) APIResultHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	p, ok := h.apiPuzzle(w, r)
+	if !ok {
+		return
+	}
+	playerID := h.playerID(r, r.URL.Query().Get("playerId"))
+	if playerID == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+	session, ok := h.game.Sessions.Lookup(p.GameID, playerID)
+	if !ok {
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_player", "No game found for this player")
+		return
+	}
+
+	state := session.State()
+	response := resultResponse{
+		GameID:           p.GameID,
+		Finished:         state.Finished,
+		Solved:           state.Solved,
+		GuessesUsed:      state.Guesses,
+		RemainingGuesses: state.RemainingGuesses,
+		HintsUsed:        len(state.HintsOpened),
+		HintsOpened:      state.HintsOpened,
+		Feedback:         state.Feedback,
+	}
+	if state.Finished {
+		response.Word = p.Word
+	}
+	utils.RespondJSON(w, http.StatusOK, response)
+}
+
+func (h *Handlers) APIStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	p, ok := h.apiPuzzle(w, r)
+	if !ok {
+		return
+	}
+	playerID := h.playerID(r, r.URL.Query().Get("playerId"))
+	if playerID == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+
+	stats, err := h.game.Stats.PlayerStats(p.GameID, playerID)
+	if err != nil {
+		respondStatsError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, stats)
+}
+
+// APIHistoryHandler reports a player's record across every game they have
+// finished.
+func (h *Handlers) APIHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	playerID, _, ok := h.keys.Verify(playerPurpose, r.PathValue("playerId"))
+	if !ok {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+	// A browser with its own player must not read someone else's history
+	// by putting their token in the path.
+	if self := h.playerID(r, ""); self != "" && self != playerID {
+		utils.RespondErrorCode(w, http.StatusForbidden, "player_mismatch", "Player ID does not match the signed-in player")
+		return
+	}
+
+	history, err := h.game.History.PlayerHistory(playerID, h.game.Current().Date)
+	if errors.Is(err, game.ErrUnknownPlayer) {
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_player", "No games recorded for this player")
+		return
+	}
+	if err != nil {
+		utils.RespondErrorCode(w, http.StatusServiceUnavailable, "history_unavailable", "Failed to get history: "+err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, history)
+}
+
+func (h *Handlers) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeFile(w, r, "web/api/openapi.json")
+}
+
+func (h *Handlers) APINotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	utils.RespondErrorCode(w, http.StatusNotFound, "not_found", "No such API endpoint")
+}
@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"references/internal/accounts"
 	"references/internal/game"
+	"references/internal/leagues"
+	"references/internal/signing"
 	"references/internal/utils"
 	"strconv"
 	"time"
@@ -20,11 +24,16 @@ func marshalToJS(v interface{}) (template.JS, error) {
 }
 
 type Handlers struct {
-	game *game.Game
+	game     *game.Game
+	accounts *accounts.Accounts
+	keys     *signing.Keys
+	leagues  *leagues.Leagues
 }
 
-func NewHandlers(g *game.Game) *Handlers {
-	return &Handlers{game: g}
+// NewHandlers builds the handlers. accts may be nil when accounts are
+// disabled; keys sign the player IDs the server issues.
+func NewHandlers(g *game.Game, accts *accounts.Accounts, keys *signing.Keys, lg *leagues.Leagues) *Handlers {
+	return &Handlers{game: g, accounts: accts, keys: keys, leagues: lg}
 }
 
 func parseTemplate(filenames ...string) (*template.Template, error) {
@@ -32,22 +41,66 @@ func parseTemplate(filenames ...string) (*template.Template, error) {
 }
 
 func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := parseTemplate("web/templates/index.html")
+	h.ensurePlayer(w, r)
+	p := h.game.Current()
+	if p.Missing {
+		h.renderNoPuzzle(w, p)
+		return
+	}
+	h.renderPuzzle(w, p, todayPage)
+}
+
+// renderNoPuzzle shows the page served while no puzzle is scheduled today.
+func (h *Handlers) renderNoPuzzle(w http.ResponseWriter, p *game.Puzzle) {
+	tmpl, err := parseTemplate("web/templates/no-puzzle.html")
 	if err != nil {
-		fmt.Printf("Error parsing index.html: %v\n", err)
+		fmt.Printf("Error parsing no-puzzle.html: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	data := struct{ FormattedDate string }{FormattedDate: p.Date.Format("2-Jan-2006")}
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Printf("Error executing no-puzzle.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handlers) PlayHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.game.Puzzle(r.PathValue("date"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if p == h.game.Current() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	h.ensurePlayer(w, r)
+	h.renderPuzzle(w, p, archivePage)
+}
+
+// puzzlePage is how the game page is shown.
+type puzzlePage int
+
+const (
+	todayPage puzzlePage = iota
+	archivePage
+	// previewPage shows an admin draft with its hints revealed and never
+	// sends guesses or hints to the game endpoints.
+	previewPage
+)
 
-	categoryEmojisJS, err := marshalToJS(h.game.GetAllCategoryEmojis())
+func (h *Handlers) renderPuzzle(w http.ResponseWriter, p *game.Puzzle, page puzzlePage) {
+	tmpl, err := parseTemplate("web/templates/index.html")
 	if err != nil {
-		fmt.Printf("Error marshalling emojis for index: %v\n", err)
+		fmt.Printf("Error parsing index.html: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	gameIDJS, err := marshalToJS(h.game.GetDailyGameID())
+
+	categoryEmojisJS, err := marshalToJS(p.GetAllCategoryEmojis())
 	if err != nil {
-		fmt.Printf("Error marshalling gameID for index: %v\n", err)
+		fmt.Printf("Error marshalling emojis for index: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -62,14 +115,26 @@ func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
 		MaskedWord     string
 		Categories     []string
 		CategoryEmojis template.JS
-		GameID         template.JS
+		GameID         string
 		BaseGameURL    template.JS
+		Archived       bool
+		Preview        bool
+		Hints          map[string]string
+		GameNumber     int
+		FormattedDate  string
 	}{
-		MaskedWord:     h.game.GetMaskedWord(),
-		Categories:     h.game.GetCategories(),
+		MaskedWord:     p.GetMaskedWord(),
+		Categories:     p.GetCategories(),
 		CategoryEmojis: categoryEmojisJS,
-		GameID:         gameIDJS,
+		GameID:         p.GameID,
 		BaseGameURL:    baseURLJS,
+		Archived:       page == archivePage,
+		Preview:        page == previewPage,
+		GameNumber:     h.game.GameNumber(p.Date),
+		FormattedDate:  p.Date.Format("2-Jan-2006"),
+	}
+	if data.Preview {
+		data.Hints = p.Hints
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -78,30 +143,81 @@ func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handlers) SuccessHandler(w http.ResponseWriter, r *http.Request) {
-	baseDate := time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC)
-	today := time.Now()
-	gameNumber := int(today.Sub(baseDate).Hours()/24) + 1
-	formattedDate := today.Format("2-Jan-2006")
+type historyBar struct {
+	Guesses int
+	Count   int
+	Percent int
+	Current bool
+}
 
-	tmpl, err := parseTemplate("web/templates/success.html")
+type historyView struct {
+	*game.PlayerHistory
+	Bars            []historyBar
+	AccountsEnabled bool
+}
+
+// historyView summarises the player's record for the result pages, with the
+// guess distribution scaled so the longest bar is full width. It is nil if
+// the player has no history.
+func (h *Handlers) historyView(state game.SessionState) *historyView {
+	history, err := h.game.History.PlayerHistory(state.PlayerID, h.game.Current().Date)
+	if err != nil {
+		return nil
+	}
+	most := 1
+	for _, n := range history.GuessDistribution {
+		most = max(most, n)
+	}
+	view := &historyView{PlayerHistory: history, AccountsEnabled: h.accounts != nil}
+	for i, n := range history.GuessDistribution {
+		view.Bars = append(view.Bars, historyBar{
+			Guesses: i + 1,
+			Count:   n,
+			Percent: max(8, 100*n/most),
+			Current: state.Solved && state.Guesses == i+1,
+		})
+	}
+	return view
+}
+
+// finishedGame resolves the puzzle and session named by the gameId and
+// playerId query parameters, and reports whether that player's game is over.
+func (h *Handlers) finishedGame(r *http.Request) (*game.Puzzle, game.SessionState, bool) {
+	gameID := r.URL.Query().Get("gameId")
+	playerID := h.playerID(r, r.URL.Query().Get("playerId"))
+	p, ok := h.game.Puzzle(gameID)
+	if !ok || playerID == "" {
+		return nil, game.SessionState{}, false
+	}
+	session, ok := h.game.Sessions.Lookup(p.GameID, playerID)
+	if !ok {
+		return nil, game.SessionState{}, false
+	}
+	state := session.State()
+	return p, state, state.Finished
+}
+
+func (h *Handlers) SuccessHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := parseTemplate("web/templates/success.html", "web/templates/history.html")
 	if err != nil {
 		fmt.Printf("Error parsing success.html: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	guessesStr := r.URL.Query().Get("guesses")
-	guesses, _ := strconv.Atoi(guessesStr)
-	if guesses <= 0 {
-		guesses = 1
+	p, state, ok := h.finishedGame(r)
+	if !ok || !state.Solved {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
 	}
-	hintsStr := r.URL.Query().Get("hints")
-	hints, _ := strconv.Atoi(hintsStr)
-	word := r.URL.Query().Get("word")
-	gameID := r.URL.Query().Get("gameId")
+	guesses := state.Guesses
+	hints := len(state.HintsOpened)
+	word := p.Word
+	gameID := p.GameID
+	gameNumber := h.game.GameNumber(p.Date)
+	formattedDate := p.Date.Format("2-Jan-2006")
 
-	categoryEmojisJS, err := marshalToJS(h.game.GetAllCategoryEmojis())
+	categoryEmojisJS, err := marshalToJS(p.GetAllCategoryEmojis())
 	if err != nil {
 		fmt.Printf("Error marshalling emojis for success: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -125,6 +241,12 @@ func (h *Handlers) SuccessHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	feedbackGridJS, err := marshalToJS(game.FeedbackGrid(state.Feedback))
+	if err != nil {
+		fmt.Printf("Error marshalling feedback for success: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
 	data := struct {
 		Word          string
@@ -138,6 +260,8 @@ func (h *Handlers) SuccessHandler(w http.ResponseWriter, r *http.Request) {
 		GameIDJS       template.JS
 		CategoryEmojis template.JS
 		BaseGameURLJS  template.JS
+		FeedbackGridJS template.JS
+		History        *historyView
 	}{
 		Word:          word,
 		Guesses:       guesses,
@@ -150,6 +274,8 @@ func (h *Handlers) SuccessHandler(w http.ResponseWriter, r *http.Request) {
 		GameIDJS:       gameIDJS,
 		CategoryEmojis: categoryEmojisJS,
 		BaseGameURLJS:  baseURLJS,
+		FeedbackGridJS: feedbackGridJS,
+		History:        h.historyView(state),
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -159,34 +285,30 @@ func (h *Handlers) SuccessHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) MaybeTomorrowHandler(w http.ResponseWriter, r *http.Request) {
-	baseDate := time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC)
-	today := time.Now()
-	gameNumber := int(today.Sub(baseDate).Hours()/24) + 1
-	formattedDate := today.Format("2-Jan-2006")
-
-	tmpl, err := parseTemplate("web/templates/maybe-tomorrow.html")
+	tmpl, err := parseTemplate("web/templates/maybe-tomorrow.html", "web/templates/history.html")
 	if err != nil {
 		fmt.Printf("Error parsing maybe-tomorrow.html: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	word := r.URL.Query().Get("word")
-	gameID := r.URL.Query().Get("gameId")
-
-	guessesStr := r.URL.Query().Get("guesses")
-	guesses, err := strconv.Atoi(guessesStr)
-	if err != nil || guesses <= 0 {
-		guesses = 4
+	p, state, ok := h.finishedGame(r)
+	if !ok {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
 	}
-
-	hintsStr := r.URL.Query().Get("hints")
-	hints, err := strconv.Atoi(hintsStr)
-	if err != nil || hints < 0 {
-		hints = 0
+	if state.Solved {
+		http.Redirect(w, r, "/success?"+r.URL.RawQuery, http.StatusSeeOther)
+		return
 	}
+	word := p.Word
+	gameID := p.GameID
+	guesses := state.Guesses
+	hints := len(state.HintsOpened)
+	gameNumber := h.game.GameNumber(p.Date)
+	formattedDate := p.Date.Format("2-Jan-2006")
 
-	categoryEmojisJS, err := marshalToJS(h.game.GetAllCategoryEmojis())
+	categoryEmojisJS, err := marshalToJS(p.GetAllCategoryEmojis())
 	if err != nil {
 		fmt.Printf("Error marshalling emojis for tomorrow: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -210,6 +332,12 @@ func (h *Handlers) MaybeTomorrowHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	feedbackGridJS, err := marshalToJS(game.FeedbackGrid(state.Feedback))
+	if err != nil {
+		fmt.Printf("Error marshalling feedback for tomorrow: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
 	data := struct {
 		Word           string
@@ -222,6 +350,8 @@ func (h *Handlers) MaybeTomorrowHandler(w http.ResponseWriter, r *http.Request)
 		GameIDJS       template.JS
 		CategoryEmojis template.JS
 		BaseGameURLJS  template.JS
+		FeedbackGridJS template.JS
+		History        *historyView
 	}{
 		Word:           word,
 		GameIDDisplay:  gameID,
@@ -233,6 +363,8 @@ func (h *Handlers) MaybeTomorrowHandler(w http.ResponseWriter, r *http.Request)
 		GameIDJS:       gameIDJS,
 		CategoryEmojis: categoryEmojisJS,
 		BaseGameURLJS:  baseURLJS,
+		FeedbackGridJS: feedbackGridJS,
+		History:        h.historyView(state),
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -241,6 +373,165 @@ func (h *Handlers) MaybeTomorrowHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// resolvePuzzle maps the gameId sent by the client to a puzzle. Clients that
+// predate the archive send no game ID and always mean today's puzzle, which
+// is not found on a day without one.
+func (h *Handlers) resolvePuzzle(gameID string) (*game.Puzzle, bool) {
+	if gameID == "" {
+		p := h.game.Current()
+		return p, !p.Missing
+	}
+	return h.game.Puzzle(gameID)
+}
+
+func (h *Handlers) ArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := parseTemplate("web/templates/archive.html")
+	if err != nil {
+		fmt.Printf("Error parsing archive.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	type archiveGame struct {
+		GameID        string
+		GameNumber    int
+		FormattedDate string
+	}
+	var games []archiveGame
+	for _, e := range h.game.Archive() {
+		games = append(games, archiveGame{
+			GameID:        e.GameID,
+			GameNumber:    e.GameNumber,
+			FormattedDate: e.Date.Format("2-Jan-2006"),
+		})
+	}
+
+	data := struct {
+		Games []archiveGame
+	}{
+		Games: games,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Printf("Error executing archive.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func respondStatsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, game.ErrUnknownGame):
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_game", "No stats recorded for this game")
+	case errors.Is(err, game.ErrUnknownPlayer):
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_player", "This player has not played this game")
+	default:
+		utils.RespondErrorCode(w, http.StatusServiceUnavailable, "stats_unavailable", "Failed to get stats: "+err.Error())
+	}
+}
+
+func respondGameError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, game.ErrNoGuessesLeft):
+		utils.RespondErrorCode(w, http.StatusConflict, "no_guesses_left", "No guesses left for this game")
+	case errors.Is(err, game.ErrAlreadySolved):
+		utils.RespondErrorCode(w, http.StatusConflict, "already_solved", "This game has already been solved")
+	case errors.Is(err, game.ErrGameOver):
+		utils.RespondErrorCode(w, http.StatusConflict, "game_over", "This game is over")
+	case errors.Is(err, game.ErrInvalidCategory):
+		utils.RespondErrorCode(w, http.StatusBadRequest, "invalid_category", err.Error())
+	default:
+		fmt.Printf("Unexpected game error: %v\n", err)
+		utils.RespondErrorCode(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+	}
+}
+
+type guessResponse struct {
+	Correct           bool                `json:"correct"`
+	Close             bool                `json:"close,omitempty"`
+	Word              string              `json:"word,omitempty"`
+	MaskedWord        string              `json:"maskedWord"`
+	RevealedPositions []int               `json:"revealedPositions,omitempty"`
+	Letters           []game.LetterResult `json:"letters,omitempty"`
+	RemainingGuesses  int                 `json:"remainingGuesses"`
+}
+
+type hintResponse struct {
+	Hint      string `json:"hint"`
+	Emoji     string `json:"emoji"`
+	HintsUsed int    `json:"hintsUsed"`
+}
+
+func (h *Handlers) submitGuess(p *game.Puzzle, playerID, guess string) (*guessResponse, error) {
+	session := h.game.Sessions.Get(p.GameID, playerID)
+	result, err := p.CheckGuess(session, guess)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &guessResponse{
+		Correct:          result.Correct,
+		Close:            result.Close,
+		MaskedWord:       p.GetPartiallyRevealedWord(session),
+		RemainingGuesses: result.RemainingGuesses,
+	}
+	if result.Correct || result.RemainingGuesses == 0 {
+		response.Word = p.Word
+	}
+	if len(result.RevealedPositions) > 0 {
+		response.RevealedPositions = result.RevealedPositions
+	}
+	response.Letters = result.Letters
+
+	// Near misses do not use up a guess, so they are kept out of the guess
+	// counts in stats.
+	eventType := "guess"
+	if result.Close {
+		eventType = "near_miss"
+	}
+	h.game.LogEvent(game.Event{
+		GameID:    p.GameID,
+		PlayerID:  playerID,
+		EventType: eventType,
+		Data: map[string]string{
+			"guess":   guess,
+			"correct": strconv.FormatBool(result.Correct),
+		},
+		Timestamp: time.Now(),
+	})
+	return response, nil
+}
+
+func (h *Handlers) revealHint(p *game.Puzzle, playerID, category string) (*hintResponse, error) {
+	hint, err := p.GetHint(category)
+	if err != nil {
+		return nil, err
+	}
+
+	emoji, err := p.GetEmoji(category)
+	if err != nil {
+		fmt.Printf("Warning: Could not get emoji for category '%s': %v\n", category, err)
+		return nil, err
+	}
+
+	hintsUsed, opened, err := h.game.Sessions.Get(p.GameID, playerID).OpenHint(category)
+	if err != nil {
+		return nil, err
+	}
+
+	if opened {
+		h.game.LogEvent(game.Event{
+			GameID:    p.GameID,
+			PlayerID:  playerID,
+			EventType: "hint",
+			Data: map[string]string{
+				"category": category,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+	return &hintResponse{Hint: hint, Emoji: emoji, HintsUsed: hintsUsed}, nil
+}
+
 func (h *Handlers) GuessHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.RespondError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
@@ -252,41 +543,30 @@ func (h *Handlers) GuessHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	guess := r.FormValue("guess")
-	playerID := r.FormValue("playerID")
+	playerID := h.playerID(r, r.FormValue("playerID"))
+	gameID := r.FormValue("gameId")
 
 	if guess == "" {
 		utils.RespondError(w, http.StatusBadRequest, "Guess cannot be empty")
 		return
 	}
+	if playerID == "" {
+		utils.RespondError(w, http.StatusBadRequest, "A valid player ID is required")
+		return
+	}
 
-	correct, revealedPositions := h.game.CheckGuess(guess)
-
-	response := struct {
-		Correct           bool   `json:"correct"`
-		Word              string `json:"word"`
-		MaskedWord        string `json:"maskedWord"`
-		RevealedPositions []int  `json:"revealedPositions,omitempty"`
-	}{
-		Correct:    correct,
-		Word:       h.game.Word,
-		MaskedWord: h.game.GetPartiallyRevealedWord(),
+	p, ok := h.resolvePuzzle(gameID)
+	if !ok {
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_game", "Unknown game")
+		return
 	}
-	if game.EnablePartialUnmasking && len(revealedPositions) > 0 {
-		response.RevealedPositions = revealedPositions
+	response, err := h.submitGuess(p, playerID, guess)
+	if err != nil {
+		respondGameError(w, err)
+		return
 	}
 
 	utils.RespondJSON(w, http.StatusOK, response)
-
-	h.game.Sheet.LogEvent(game.Event{
-		GameID:    h.game.GetDailyGameID(),
-		PlayerID:  playerID,
-		EventType: "guess",
-		Data: map[string]string{
-			"guess":   guess,
-			"correct": strconv.FormatBool(correct),
-		},
-		Timestamp: time.Now(),
-	})
 }
 
 func (h *Handlers) HintHandler(w http.ResponseWriter, r *http.Request) {
@@ -300,45 +580,30 @@ func (h *Handlers) HintHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	category := r.FormValue("category")
-	playerID := r.FormValue("playerID")
+	playerID := h.playerID(r, r.FormValue("playerID"))
+	gameID := r.FormValue("gameId")
 
 	if category == "" {
 		utils.RespondError(w, http.StatusBadRequest, "Category cannot be empty")
 		return
 	}
-
-	hint, err := h.game.GetHint(category)
-	if err != nil {
-		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	if playerID == "" {
+		utils.RespondError(w, http.StatusBadRequest, "A valid player ID is required")
 		return
 	}
 
-	emoji, err := h.game.GetEmoji(category)
-	if err != nil {
-		fmt.Printf("Warning: Could not get emoji for category '%s': %v\n", category, err)
-		utils.RespondError(w, http.StatusInternalServerError, "Could not retrieve hint details")
+	p, ok := h.resolvePuzzle(gameID)
+	if !ok {
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_game", "Unknown game")
 		return
 	}
-
-	response := struct {
-		Hint  string `json:"hint"`
-		Emoji string `json:"emoji"`
-	}{
-		Hint:  hint,
-		Emoji: emoji,
+	response, err := h.revealHint(p, playerID, category)
+	if err != nil {
+		respondGameError(w, err)
+		return
 	}
 
 	utils.RespondJSON(w, http.StatusOK, response)
-
-	h.game.Sheet.LogEvent(game.Event{
-		GameID:    h.game.GetDailyGameID(),
-		PlayerID:  playerID,
-		EventType: "hint",
-		Data: map[string]string{
-			"category": category,
-		},
-		Timestamp: time.Now(),
-	})
 }
 
 func (h *Handlers) StatsHandler(w http.ResponseWriter, r *http.Request) {
@@ -348,19 +613,27 @@ func (h *Handlers) StatsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	gameID := r.URL.Query().Get("gameId")
-	playerID := r.URL.Query().Get("playerId")
+	playerID := h.playerID(r, r.URL.Query().Get("playerId"))
 
 	if gameID == "" || playerID == "" {
 		utils.RespondError(w, http.StatusBadRequest, "Game ID and Player ID are required")
 		return
 	}
 
-	stats, err := h.game.Sheet.GetPlayerStats(gameID, playerID)
-
+	stats, err := h.game.Stats.PlayerStats(gameID, playerID)
 	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, "Failed to get stats: "+err.Error())
+		respondStatsError(w, err)
 		return
 	}
 
 	utils.RespondJSON(w, http.StatusOK, stats)
 }
+
+func (h *Handlers) AnalyticsStatusHandler(w http.ResponseWriter, r *http.Request) {
+	counters, ok := h.game.Sheet.AnalyticsCounters()
+	if !ok {
+		utils.RespondErrorCode(w, http.StatusNotFound, "analytics_disabled", "Analytics is not enabled")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, counters)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"references/internal/game"
+	"references/internal/utils"
+)
+
+const (
+	leaderboardPageSize = 50
+	leaderboardMaxLimit = 500
+	anonymousName       = "Anonymous"
+)
+
+type leaderboardRow struct {
+	Rank        int    `json:"rank"`
+	DisplayName string `json:"displayName"`
+	Guesses     int    `json:"guesses"`
+	Hints       int    `json:"hints"`
+	SolvedAt    string `json:"solvedAt"`
+	You         bool   `json:"you,omitempty"`
+}
+
+type leaderboardResponse struct {
+	GameID  string           `json:"gameId"`
+	Solvers int              `json:"solvers"`
+	Entries []leaderboardRow `json:"entries"`
+	// You is the requesting player's row when it falls outside Entries.
+	You *leaderboardRow `json:"you,omitempty"`
+}
+
+// leaderboard returns the top limit rows of a game's leaderboard, plus
+// playerID's row if they solved it but did not make the cut.
+func (h *Handlers) leaderboard(gameID, playerID string, limit int) (leaderboardResponse, error) {
+	resp := leaderboardResponse{GameID: gameID, Entries: []leaderboardRow{}}
+	entries, err := h.game.Stats.Leaderboard(gameID)
+	if errors.Is(err, game.ErrUnknownGame) {
+		return resp, nil
+	}
+	if err != nil {
+		return resp, err
+	}
+	resp.Solvers = len(entries)
+	for i, e := range entries {
+		you := playerID != "" && e.PlayerID == playerID
+		if i >= limit && !you {
+			continue
+		}
+		name := h.game.Names.Get(e.PlayerID)
+		if name == "" {
+			name = anonymousName
+		}
+		row := leaderboardRow{
+			Rank:        e.Rank,
+			DisplayName: name,
+			Guesses:     e.Guesses,
+			Hints:       e.Hints,
+			SolvedAt:    e.SolvedAt.Format(time.RFC3339),
+			You:         you,
+		}
+		if i < limit {
+			resp.Entries = append(resp.Entries, row)
+		} else {
+			resp.You = &row
+		}
+	}
+	return resp, nil
+}
+
+// LeaderboardHandler shows a game's leaderboard, today's by default.
+func (h *Handlers) LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.resolvePuzzle(r.URL.Query().Get("gameId"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.ensurePlayer(w, r)
+	playerID := h.playerID(r, "")
+	board, err := h.leaderboard(p.GameID, playerID, leaderboardPageSize)
+	if err != nil {
+		fmt.Printf("Error building leaderboard: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := parseTemplate("web/templates/leaderboard.html")
+	if err != nil {
+		fmt.Printf("Error parsing leaderboard.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		leaderboardResponse
+		GameNumber    int
+		FormattedDate string
+		DisplayName   string
+		NameError     string
+	}{
+		leaderboardResponse: board,
+		GameNumber:          h.game.GameNumber(p.Date),
+		FormattedDate:       p.Date.Format("2-Jan-2006"),
+		DisplayName:         h.game.Names.Get(playerID),
+		NameError:           r.URL.Query().Get("nameError"),
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Printf("Error executing leaderboard.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// LeaderboardNameHandler sets the display name shown for the player on
+// leaderboards, then returns to the leaderboard they came from.
+func (h *Handlers) LeaderboardNameHandler(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	back := "/leaderboard?gameId=" + url.QueryEscape(r.PostFormValue("gameId"))
+	playerID := h.playerID(r, "")
+	if playerID == "" {
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if _, err := h.game.Names.Set(playerID, r.PostFormValue("displayName")); err != nil {
+		fmt.Printf("Error setting display name: %v\n", err)
+		msg := "Something went wrong saving your name."
+		if errors.Is(err, game.ErrInvalidDisplayName) {
+			msg = "Names can be up to 24 characters."
+		}
+		back += "&nameError=" + url.QueryEscape(msg)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// APILeaderboardHandler returns a game's leaderboard. limit caps the number
+// of rows; playerId, if given, adds the player's own row.
+func (h *Handlers) APILeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	p, ok := h.apiPuzzle(w, r)
+	if !ok {
+		return
+	}
+	limit := leaderboardPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > leaderboardMaxLimit {
+			utils.RespondErrorCode(w, http.StatusBadRequest, "invalid_limit", fmt.Sprintf("limit must be between 1 and %d", leaderboardMaxLimit))
+			return
+		}
+		limit = n
+	}
+	board, err := h.leaderboard(p.GameID, h.playerID(r, r.URL.Query().Get("playerId")), limit)
+	if err != nil {
+		utils.RespondErrorCode(w, http.StatusServiceUnavailable, "leaderboard_unavailable", "Failed to get leaderboard: "+err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, board)
+}
+
+type displayNameRequest struct {
+	DisplayName string `json:"displayName"`
+}
+
+// APIPlayerNameHandler sets the display name a player is shown under on
+// leaderboards. An empty name goes back to anonymous.
+func (h *Handlers) APIPlayerNameHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPut) {
+		return
+	}
+	var req displayNameRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	playerID := h.playerID(r, r.PathValue("playerId"))
+	if playerID == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+	name, err := h.game.Names.Set(playerID, req.DisplayName)
+	if errors.Is(err, game.ErrInvalidDisplayName) {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "invalid_display_name", err.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondErrorCode(w, http.StatusServiceUnavailable, "names_unavailable", "Failed to save display name: "+err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, displayNameRequest{DisplayName: name})
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"references/internal/leagues"
+	"references/internal/utils"
+)
+
+type leaguesPage struct {
+	Leagues        []leagues.League
+	DisplayName    string
+	DefaultScoring leagues.Scoring
+	Error          string
+}
+
+// LeaguesHandler lists the player's leagues, with forms to create or join
+// one.
+func (h *Handlers) LeaguesHandler(w http.ResponseWriter, r *http.Request) {
+	h.ensurePlayer(w, r)
+	h.renderLeagues(w, r, http.StatusOK, "")
+}
+
+func (h *Handlers) renderLeagues(w http.ResponseWriter, r *http.Request, status int, errMsg string) {
+	tmpl, err := parseTemplate("web/templates/leagues.html")
+	if err != nil {
+		fmt.Printf("Error parsing leagues.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	playerID := h.playerID(r, "")
+	data := leaguesPage{
+		DisplayName:    h.game.Names.Get(playerID),
+		DefaultScoring: h.leagues.DefaultScoring,
+		Error:          errMsg,
+	}
+	if playerID != "" {
+		data.Leagues = h.leagues.ForPlayer(playerID)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Printf("Error executing leagues.html: %v\n", err)
+	}
+}
+
+// leagueForm checks a league form post and saves the display name sent with
+// it, returning the player it acts as.
+func (h *Handlers) leagueForm(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !sameOrigin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return "", false
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return "", false
+	}
+	playerID := h.playerID(r, "")
+	if playerID == "" {
+		h.renderLeagues(w, r, http.StatusBadRequest, "Play a game first so we know who you are.")
+		return "", false
+	}
+	if name := r.PostFormValue("displayName"); name != "" {
+		if _, err := h.game.Names.Set(playerID, name); err != nil {
+			h.renderLeagues(w, r, http.StatusBadRequest, "Names can be up to 24 characters.")
+			return "", false
+		}
+	}
+	return playerID, true
+}
+
+func (h *Handlers) LeagueCreateHandler(w http.ResponseWriter, r *http.Request) {
+	playerID, ok := h.leagueForm(w, r)
+	if !ok {
+		return
+	}
+	var terms []string
+	for _, term := range []string{"solve", "guess", "hint", "fail"} {
+		if v := strings.TrimSpace(r.PostFormValue(term)); v != "" {
+			terms = append(terms, term+"="+v)
+		}
+	}
+	scoring, err := leagues.ParseScoring(strings.Join(terms, ","), h.leagues.DefaultScoring)
+	if err != nil {
+		h.renderLeagues(w, r, http.StatusBadRequest, "Points must be whole numbers from 0 to 1000.")
+		return
+	}
+	l, err := h.leagues.Create(playerID, r.PostFormValue("name"), scoring)
+	if errors.Is(err, leagues.ErrInvalidName) {
+		h.renderLeagues(w, r, http.StatusBadRequest, "League names can be up to 40 characters.")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error creating league: %v\n", err)
+		h.renderLeagues(w, r, http.StatusInternalServerError, "Something went wrong creating the league. Please try again.")
+		return
+	}
+	http.Redirect(w, r, "/leagues/"+url.PathEscape(l.ID), http.StatusSeeOther)
+}
+
+func (h *Handlers) LeagueJoinHandler(w http.ResponseWriter, r *http.Request) {
+	playerID, ok := h.leagueForm(w, r)
+	if !ok {
+		return
+	}
+	l, err := h.leagues.Join(r.PostFormValue("code"), playerID)
+	switch {
+	case errors.Is(err, leagues.ErrUnknownInvite):
+		h.renderLeagues(w, r, http.StatusNotFound, "No league has that invite code.")
+	case errors.Is(err, leagues.ErrLeagueFull):
+		h.renderLeagues(w, r, http.StatusConflict, "That league is full.")
+	case err != nil:
+		fmt.Printf("Error joining league: %v\n", err)
+		h.renderLeagues(w, r, http.StatusInternalServerError, "Something went wrong joining the league. Please try again.")
+	default:
+		http.Redirect(w, r, "/leagues/"+url.PathEscape(l.ID), http.StatusSeeOther)
+	}
+}
+
+func (h *Handlers) LeagueLeaveHandler(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	err := h.leagues.Leave(r.PathValue("id"), h.playerID(r, ""))
+	if err != nil && !errors.Is(err, leagues.ErrUnknownLeague) && !errors.Is(err, leagues.ErrNotMember) {
+		fmt.Printf("Error leaving league: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/leagues", http.StatusSeeOther)
+}
+
+// LeagueHandler shows a league's standings to its members. Other players
+// get a 404, so league IDs reveal nothing.
+func (h *Handlers) LeagueHandler(w http.ResponseWriter, r *http.Request) {
+	h.ensurePlayer(w, r)
+	playerID := h.playerID(r, "")
+	l, err := h.leagues.Get(r.PathValue("id"), playerID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	p, ok := h.resolvePuzzle(r.URL.Query().Get("date"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	tmpl, err := parseTemplate("web/templates/league.html")
+	if err != nil {
+		fmt.Printf("Error parsing league.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		leagues.Standings
+		GameNumber    int
+		FormattedDate string
+	}{
+		Standings:     l.Standings(h.game.History, h.game.Names, p.Date, playerID, anonymousName),
+		GameNumber:    h.game.GameNumber(p.Date),
+		FormattedDate: p.Date.Format("2-Jan-2006"),
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Printf("Error executing league.html: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+type apiLeagueRequest struct {
+	PlayerID   string           `json:"playerId"`
+	Name       string           `json:"name"`
+	Scoring    *leagues.Scoring `json:"scoring,omitempty"`
+	InviteCode string           `json:"inviteCode"`
+}
+
+type leagueResponse struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	InviteCode string          `json:"inviteCode"`
+	Scoring    leagues.Scoring `json:"scoring"`
+	Members    int             `json:"members"`
+}
+
+func newLeagueResponse(l leagues.League) leagueResponse {
+	return leagueResponse{ID: l.ID, Name: l.Name, InviteCode: l.InviteCode, Scoring: l.Scoring, Members: len(l.Members)}
+}
+
+// APILeagueCreateHandler creates a league owned by the player. The league
+// uses the server's default scoring unless the request sets its own.
+func (h *Handlers) APILeagueCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req apiLeagueRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	playerID := h.playerID(r, req.PlayerID)
+	if playerID == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+	scoring := h.leagues.DefaultScoring
+	if req.Scoring != nil {
+		// Round-trip through the parser so the same limits apply.
+		sc, err := leagues.ParseScoring(req.Scoring.String(), scoring)
+		if err != nil {
+			utils.RespondErrorCode(w, http.StatusBadRequest, "invalid_scoring", err.Error())
+			return
+		}
+		scoring = sc
+	}
+	l, err := h.leagues.Create(playerID, req.Name, scoring)
+	if errors.Is(err, leagues.ErrInvalidName) {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "invalid_league_name", err.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondErrorCode(w, http.StatusServiceUnavailable, "leagues_unavailable", "Failed to create league: "+err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, newLeagueResponse(l))
+}
+
+func (h *Handlers) APILeagueJoinHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req apiLeagueRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	playerID := h.playerID(r, req.PlayerID)
+	if playerID == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+	l, err := h.leagues.Join(req.InviteCode, playerID)
+	switch {
+	case errors.Is(err, leagues.ErrUnknownInvite):
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_invite", "No league has that invite code")
+	case errors.Is(err, leagues.ErrLeagueFull):
+		utils.RespondErrorCode(w, http.StatusConflict, "league_full", "This league is full")
+	case err != nil:
+		utils.RespondErrorCode(w, http.StatusServiceUnavailable, "leagues_unavailable", "Failed to join league: "+err.Error())
+	default:
+		utils.RespondJSON(w, http.StatusOK, newLeagueResponse(l))
+	}
+}
+
+// APILeagueStandingsHandler returns a league's standings to one of its
+// members, for today or the game given by date.
+func (h *Handlers) APILeagueStandingsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	playerID := h.playerID(r, r.URL.Query().Get("playerId"))
+	if playerID == "" {
+		utils.RespondErrorCode(w, http.StatusBadRequest, "missing_player", "A valid player ID is required")
+		return
+	}
+	l, err := h.leagues.Get(r.PathValue("leagueId"), playerID)
+	if err != nil {
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_league", "No such league, or you are not a member")
+		return
+	}
+	date := r.URL.Query().Get("date")
+	if date == apiTodayID {
+		date = ""
+	}
+	p, ok := h.resolvePuzzle(date)
+	if !ok {
+		utils.RespondErrorCode(w, http.StatusNotFound, "unknown_game", "Unknown game")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, l.Standings(h.game.History, h.game.Names, p.Date, playerID, anonymousName))
+}
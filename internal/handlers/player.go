@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"references/internal/config"
+	"references/internal/utils"
+)
+
+const (
+	playerCookie     = "references_player"
+	playerPurpose    = "player"
+	playerCookieTTL  = 400 * 24 * time.Hour
+	playerIDRandSize = 16
+)
+
+// ensurePlayer makes sure the browser holds a signed player cookie, issuing a
+// new player ID on first visit and re-signing cookies from a retired key.
+func (h *Handlers) ensurePlayer(w http.ResponseWriter, r *http.Request) {
+	if h.accounts != nil {
+		h.accounts.RefreshSession(w, r)
+	}
+	if c, err := r.Cookie(playerCookie); err == nil {
+		id, stale, ok := h.keys.Verify(playerPurpose, c.Value)
+		if ok && !stale {
+			return
+		}
+		if ok {
+			h.setPlayerCookie(w, id)
+			return
+		}
+	}
+	id, err := newPlayerID()
+	if err != nil {
+		fmt.Printf("Error generating player ID: %v\n", err)
+		return
+	}
+	h.setPlayerCookie(w, id)
+}
+
+func (h *Handlers) setPlayerCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     playerCookie,
+		Value:    h.keys.Sign(playerPurpose, id),
+		Path:     "/",
+		Expires:  time.Now().Add(playerCookieTTL),
+		HttpOnly: true,
+		Secure:   h.game.Cfg.Mode == config.ModeProd,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// playerID returns the verified player a request acts as, or "" if it has
+// none. A signed-in browser plays as its account's player ID; otherwise the
+// player cookie is used. claimed is what the client sent, which is only
+// trusted if it is itself a player token from APIPlayerHandler.
+func (h *Handlers) playerID(r *http.Request, claimed string) string {
+	if h.accounts != nil {
+		if acct, ok := h.accounts.FromRequest(r); ok && acct.PlayerID != "" {
+			return acct.PlayerID
+		}
+	}
+	if c, err := r.Cookie(playerCookie); err == nil {
+		if id, _, ok := h.keys.Verify(playerPurpose, c.Value); ok {
+			return id
+		}
+	}
+	if id, _, ok := h.keys.Verify(playerPurpose, claimed); ok {
+		return id
+	}
+	return ""
+}
+
+func newPlayerID() (string, error) {
+	b := make([]byte, playerIDRandSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "p-" + hex.EncodeToString(b), nil
+}
+
+type playerResponse struct {
+	PlayerID string `json:"playerId"`
+}
+
+// APIPlayerHandler issues a new player token for API clients, which send it
+// as playerId in later requests.
+func (h *Handlers) APIPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	id, err := newPlayerID()
+	if err != nil {
+		utils.RespondErrorCode(w, http.StatusInternalServerError, "player_unavailable", "Failed to issue player ID: "+err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, playerResponse{PlayerID: h.keys.Sign(playerPurpose, id)})
+}
@@ -0,0 +1,283 @@
+// Package leagues lets groups of players compare results privately. A league
+// is joined with its invite code, and its standings are built from the same
+// guess and hint events as player history.
+package leagues
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"references/internal/config"
+)
+
+const (
+	maxMembers    = 100
+	maxNameLength = 40
+	inviteLength  = 8
+	// inviteAlphabet leaves out letters and digits that are easy to confuse.
+	inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var (
+	ErrUnknownLeague = errors.New("unknown league")
+	ErrUnknownInvite = errors.New("unknown invite code")
+	ErrLeagueFull    = errors.New("league is full")
+	ErrInvalidName   = errors.New("invalid league name")
+	ErrNotMember     = errors.New("not a member of this league")
+)
+
+type League struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	InviteCode string    `json:"inviteCode"`
+	Owner      string    `json:"owner"`
+	Members    []string  `json:"members"`
+	Scoring    Scoring   `json:"scoring"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (l League) IsMember(playerID string) bool {
+	for _, m := range l.Members {
+		if m == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// Leagues keeps every league in a JSON file, rewritten on every change.
+type Leagues struct {
+	// DefaultScoring is used for leagues created without their own formula.
+	DefaultScoring Scoring
+
+	mu      sync.Mutex
+	path    string
+	leagues map[string]*League
+}
+
+func New(cfg config.Config) (*Leagues, error) {
+	sc, err := ParseScoring(cfg.LeagueScoring, Scoring{})
+	if err != nil {
+		return nil, fmt.Errorf("LEAGUE_SCORING: %w", err)
+	}
+	return Open(filepath.Join(cfg.DataDir, "leagues.json"), sc)
+}
+
+func Open(path string, def Scoring) (*Leagues, error) {
+	ls := &Leagues{DefaultScoring: def, path: path, leagues: make(map[string]*League)}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ls, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read leagues: %w", err)
+	}
+	var list []*League
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	for _, l := range list {
+		ls.leagues[l.ID] = l
+	}
+	return ls, nil
+}
+
+// Create starts a league with owner as its only member.
+func (ls *Leagues) Create(owner, name string, sc Scoring) (League, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return League{}, fmt.Errorf("%w: use 1 to %d characters", ErrInvalidName, maxNameLength)
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return League{}, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	code, err := ls.newInviteCode()
+	if err != nil {
+		return League{}, err
+	}
+	l := &League{
+		ID:         id,
+		Name:       name,
+		InviteCode: code,
+		Owner:      owner,
+		Members:    []string{owner},
+		Scoring:    sc,
+		CreatedAt:  time.Now(),
+	}
+	ls.leagues[id] = l
+	return *l, ls.save()
+}
+
+// Join adds playerID to the league with the invite code. Joining a league
+// again is not an error.
+func (ls *Leagues) Join(code, playerID string) (League, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for _, l := range ls.leagues {
+		if l.InviteCode != code {
+			continue
+		}
+		if l.IsMember(playerID) {
+			return *l, nil
+		}
+		if len(l.Members) >= maxMembers {
+			return League{}, ErrLeagueFull
+		}
+		l.Members = append(l.Members, playerID)
+		return *l, ls.save()
+	}
+	return League{}, ErrUnknownInvite
+}
+
+// Leave removes playerID from a league. The next member takes over from an
+// owner who leaves, and a league nobody is left in is deleted.
+func (ls *Leagues) Leave(id, playerID string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, ok := ls.leagues[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownLeague, id)
+	}
+	if !l.IsMember(playerID) {
+		return ErrNotMember
+	}
+	l.Members = remove(l.Members, playerID)
+	switch {
+	case len(l.Members) == 0:
+		delete(ls.leagues, id)
+	case l.Owner == playerID:
+		l.Owner = l.Members[0]
+	}
+	return ls.save()
+}
+
+// Get returns a league for one of its members.
+func (ls *Leagues) Get(id, playerID string) (League, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, ok := ls.leagues[id]
+	if !ok {
+		return League{}, fmt.Errorf("%w: %s", ErrUnknownLeague, id)
+	}
+	if !l.IsMember(playerID) {
+		return League{}, ErrNotMember
+	}
+	return *l, nil
+}
+
+// ForPlayer lists the leagues playerID is in, by name.
+func (ls *Leagues) ForPlayer(playerID string) []League {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	var out []League
+	for _, l := range ls.leagues {
+		if l.IsMember(playerID) {
+			out = append(out, *l)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// ReplaceMember moves from's memberships to to, for when an account claims
+// an anonymous player ID.
+func (ls *Leagues) ReplaceMember(from, to string) error {
+	if from == to {
+		return nil
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	changed := false
+	for _, l := range ls.leagues {
+		if !l.IsMember(from) {
+			continue
+		}
+		l.Members = remove(l.Members, from)
+		if !l.IsMember(to) {
+			l.Members = append(l.Members, to)
+		}
+		if l.Owner == from {
+			l.Owner = to
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return ls.save()
+}
+
+func (ls *Leagues) newInviteCode() (string, error) {
+	for {
+		b := make([]byte, inviteLength)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for i := range b {
+			b[i] = inviteAlphabet[int(b[i])%len(inviteAlphabet)]
+		}
+		code := string(b)
+		taken := false
+		for _, l := range ls.leagues {
+			if l.InviteCode == code {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+}
+
+func (ls *Leagues) save() error {
+	list := make([]*League, 0, len(ls.leagues))
+	for _, l := range ls.leagues {
+		list = append(list, l)
+	}
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ls.path), 0o755); err != nil {
+		return err
+	}
+	tmp := ls.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ls.path)
+}
+
+func remove(ids []string, id string) []string {
+	out := make([]string, 0, len(ids))
+	for _, m := range ids {
+		if m != id {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,60 @@
+package leagues
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"references/internal/game"
+)
+
+// Scoring turns a game result into points: Solve for solving, less PerGuess
+// for each guess after the first and PerHint for each hint, but never below
+// zero. A game that was not solved scores Fail.
+type Scoring struct {
+	Solve    int `json:"solve"`
+	PerGuess int `json:"perGuess"`
+	PerHint  int `json:"perHint"`
+	Fail     int `json:"fail"`
+}
+
+// ParseScoring reads a formula such as "solve=10,guess=2,hint=1,fail=0".
+// Terms left out keep their value from def.
+func ParseScoring(s string, def Scoring) (Scoring, error) {
+	sc := def
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(term, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || n < 0 || n > 1000 {
+			return Scoring{}, fmt.Errorf("invalid scoring term %q: want name=N with N from 0 to 1000", term)
+		}
+		switch strings.TrimSpace(name) {
+		case "solve":
+			sc.Solve = n
+		case "guess":
+			sc.PerGuess = n
+		case "hint":
+			sc.PerHint = n
+		case "fail":
+			sc.Fail = n
+		default:
+			return Scoring{}, fmt.Errorf("unknown scoring term %q: want solve, guess, hint or fail", name)
+		}
+	}
+	return sc, nil
+}
+
+func (sc Scoring) String() string {
+	return fmt.Sprintf("solve=%d,guess=%d,hint=%d,fail=%d", sc.Solve, sc.PerGuess, sc.PerHint, sc.Fail)
+}
+
+func (sc Scoring) Points(r game.GameResult) int {
+	if !r.Solved {
+		return sc.Fail
+	}
+	return max(0, sc.Solve-sc.PerGuess*(r.Guesses-1)-sc.PerHint*r.Hints)
+}
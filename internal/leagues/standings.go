@@ -0,0 +1,109 @@
+package leagues
+
+import (
+	"sort"
+	"time"
+
+	"references/internal/game"
+)
+
+const dateLayout = "2006-01-02"
+
+type DayResult struct {
+	Guesses int  `json:"guesses"`
+	Hints   int  `json:"hints"`
+	Solved  bool `json:"solved"`
+	Points  int  `json:"points"`
+}
+
+type Row struct {
+	Rank        int    `json:"rank"`
+	PlayerID    string `json:"-"`
+	DisplayName string `json:"displayName"`
+	You         bool   `json:"you,omitempty"`
+	// Today is nil until the member has finished the day's game.
+	Today       *DayResult `json:"today,omitempty"`
+	Week        int        `json:"week"`
+	WeekPlayed  int        `json:"weekPlayed"`
+	Month       int        `json:"month"`
+	MonthPlayed int        `json:"monthPlayed"`
+}
+
+type Standings struct {
+	LeagueID   string  `json:"leagueId"`
+	Name       string  `json:"name"`
+	InviteCode string  `json:"inviteCode"`
+	Scoring    Scoring `json:"scoring"`
+	Date       string  `json:"date"`
+	WeekStart  string  `json:"weekStart"`
+	MonthStart string  `json:"monthStart"`
+	Rows       []Row   `json:"rows"`
+}
+
+// Standings scores every member for the game on day and for the week (from
+// Monday) and month it falls in, ranked by weekly points. Only games played
+// on their day count; viewer's row is marked. Members without a display
+// name are shown as anonymous.
+func (l League) Standings(history *game.HistoryStore, names *game.NameStore, day time.Time, viewer, anonymous string) Standings {
+	weekStart := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	monthStart := day.AddDate(0, 0, 1-day.Day())
+	from := weekStart
+	if monthStart.Before(from) {
+		from = monthStart
+	}
+
+	st := Standings{
+		LeagueID:   l.ID,
+		Name:       l.Name,
+		InviteCode: l.InviteCode,
+		Scoring:    l.Scoring,
+		Date:       day.Format(dateLayout),
+		WeekStart:  weekStart.Format(dateLayout),
+		MonthStart: monthStart.Format(dateLayout),
+		Rows:       make([]Row, 0, len(l.Members)),
+	}
+	for _, id := range l.Members {
+		row := Row{PlayerID: id, DisplayName: names.Get(id), You: id == viewer}
+		if row.DisplayName == "" {
+			row.DisplayName = anonymous
+		}
+		for d := from; !d.After(day); d = d.AddDate(0, 0, 1) {
+			res, ok := history.Result(id, d)
+			if !ok {
+				continue
+			}
+			points := l.Scoring.Points(res)
+			if !d.Before(weekStart) {
+				row.Week += points
+				row.WeekPlayed++
+			}
+			if !d.Before(monthStart) {
+				row.Month += points
+				row.MonthPlayed++
+			}
+			if d.Equal(day) {
+				row.Today = &DayResult{Guesses: res.Guesses, Hints: res.Hints, Solved: res.Solved, Points: points}
+			}
+		}
+		st.Rows = append(st.Rows, row)
+	}
+
+	sort.SliceStable(st.Rows, func(i, j int) bool {
+		x, y := st.Rows[i], st.Rows[j]
+		if x.Week != y.Week {
+			return x.Week > y.Week
+		}
+		if x.Month != y.Month {
+			return x.Month > y.Month
+		}
+		return x.DisplayName < y.DisplayName
+	})
+	for i := range st.Rows {
+		if i > 0 && st.Rows[i].Week == st.Rows[i-1].Week {
+			st.Rows[i].Rank = st.Rows[i-1].Rank
+		} else {
+			st.Rows[i].Rank = i + 1
+		}
+	}
+	return st
+}
@@ -0,0 +1,114 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+type worker struct {
+	name string
+	done chan struct{}
+}
+
+type stopHook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Lifecycle owns the HTTP server and the background work around it. On
+// SIGINT or SIGTERM it stops accepting traffic, waits for in-flight requests,
+// stops the workers and then runs the stop hooks, all within one deadline.
+type Lifecycle struct {
+	srv     *http.Server
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers []*worker
+	hooks   []stopHook
+}
+
+func New(srv *http.Server, timeout time.Duration) *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{srv: srv, timeout: timeout, ctx: ctx, cancel: cancel}
+}
+
+// Go starts run in the background. Its context is cancelled once the HTTP
+// server has stopped, and shutdown waits for it to return.
+func (l *Lifecycle) Go(name string, run func(ctx context.Context)) {
+	w := &worker{name: name, done: make(chan struct{})}
+	l.workers = append(l.workers, w)
+	go func() {
+		defer close(w.done)
+		run(l.ctx)
+	}()
+}
+
+// OnStop registers a hook that runs after the server and workers have
+// stopped. Hooks run in the order they were added.
+func (l *Lifecycle) OnStop(name string, stop func(ctx context.Context) error) {
+	l.hooks = append(l.hooks, stopHook{name: name, stop: stop})
+}
+
+// Run serves until a shutdown signal or a server error and then shuts
+// everything down. It returns the server error, if any.
+func (l *Lifecycle) Run() error {
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", l.srv.Addr)
+		errCh <- l.srv.ListenAndServe()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	var serveErr error
+	select {
+	case sig := <-sigCh:
+		log.Printf("shutdown signal: %v", sig)
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			serveErr = err
+		}
+	}
+
+	l.shutdown()
+	return serveErr
+}
+
+func (l *Lifecycle) shutdown() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	if err := l.srv.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: http server: in-flight requests abandoned: %v", err)
+	} else {
+		log.Printf("shutdown: http server stopped")
+	}
+
+	l.cancel()
+	for _, w := range l.workers {
+		select {
+		case <-w.done:
+			log.Printf("shutdown: %s stopped", w.name)
+		case <-ctx.Done():
+			log.Printf("shutdown: %s abandoned at deadline", w.name)
+		}
+	}
+
+	for _, h := range l.hooks {
+		if err := h.stop(ctx); err != nil {
+			log.Printf("shutdown: %s: %v", h.name, err)
+		} else {
+			log.Printf("shutdown: %s done", h.name)
+		}
+	}
+	log.Printf("shutdown complete in %s", time.Since(start).Round(time.Millisecond))
+}
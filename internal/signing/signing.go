@@ -0,0 +1,113 @@
+// Package signing issues and checks HMAC-signed tokens. Several keys can be
+// configured so they can be rotated: the first signs, and any of them
+// verifies.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+
+	"references/internal/config"
+)
+
+type key struct {
+	id     string
+	secret []byte
+}
+
+type Keys struct {
+	keys []key
+}
+
+// Load parses SIGNING_KEYS, a comma-separated list of id:secret pairs with
+// the current key first. Without keys, prod refuses to start and local mode
+// uses a random key, so tokens do not survive a restart.
+func Load(cfg config.Config) (*Keys, error) {
+	var k Keys
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(cfg.SigningKeys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || strings.Contains(id, ".") || len(secret) < 16 {
+			return nil, fmt.Errorf("invalid SIGNING_KEYS entry %q: want id:secret with a secret of at least 16 characters", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("SIGNING_KEYS has key %q twice", id)
+		}
+		seen[id] = true
+		k.keys = append(k.keys, key{id: id, secret: []byte(secret)})
+	}
+	if len(k.keys) > 0 {
+		return &k, nil
+	}
+	if cfg.Mode == config.ModeProd {
+		return nil, fmt.Errorf("SIGNING_KEYS is required in prod")
+	}
+	log.Printf("signing: SIGNING_KEYS is not set, using a random key; player IDs and sign-ins will not survive a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	k.keys = []key{{id: "local", secret: secret}}
+	return &k, nil
+}
+
+// Sign returns a token carrying value. purpose keeps tokens issued for one
+// use from being accepted for another.
+func (k *Keys) Sign(purpose, value string) string {
+	cur := k.keys[0]
+	body := cur.id + "." + base64.RawURLEncoding.EncodeToString([]byte(value))
+	return body + "." + mac(cur.secret, purpose, body)
+}
+
+// Verify returns the value in a token signed by any configured key. stale
+// reports that it was signed by a key other than the current one, so the
+// caller can reissue it.
+func (k *Keys) Verify(purpose, token string) (value string, stale bool, ok bool) {
+	body, sig, found := cutLast(token, ".")
+	if !found {
+		return "", false, false
+	}
+	id, encoded, found := strings.Cut(body, ".")
+	if !found {
+		return "", false, false
+	}
+	for i, key := range k.keys {
+		if key.id != id {
+			continue
+		}
+		if !hmac.Equal([]byte(sig), []byte(mac(key.secret, purpose, body))) {
+			return "", false, false
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", false, false
+		}
+		return string(raw), i > 0, true
+	}
+	return "", false, false
+}
+
+func mac(secret []byte, purpose, body string) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(purpose))
+	m.Write([]byte{0})
+	m.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
@@ -11,6 +11,15 @@ func RespondJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
 func RespondError(w http.ResponseWriter, code int, message string) {
-	RespondJSON(w, code, map[string]string{"error": message})
+	RespondJSON(w, code, ErrorResponse{Error: message})
+}
+
+func RespondErrorCode(w http.ResponseWriter, status int, code, message string) {
+	RespondJSON(w, status, ErrorResponse{Error: message, Code: code})
 }
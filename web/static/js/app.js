@@ -5,32 +5,22 @@ document.addEventListener('DOMContentLoaded', function () {
         console.error("Fatal: game-container element not found.");
         return;
     }
-    function generatePlayerID() {
-        const playerIDKey = 'references-player-id';
-        let playerID = localStorage.getItem(playerIDKey);
-        
-        if (!playerID) {
-            // Generate a random ID
-            playerID = 'player-' + Math.random().toString(36).substring(2, 15) + 
-                      Math.random().toString(36).substring(2, 15);
-            localStorage.setItem(playerIDKey, playerID);
-        }
-        
-        return playerID;
-    }
     const gameId = String(gameContainer.dataset.gameId);
+    // Admin previews show a draft: nothing is sent to the game or stored.
+    const preview = gameContainer.dataset.preview === 'true';
     const guessesLeftElem = document.getElementById('guesses-left');
     const hintsUsedElem = document.getElementById('hints-used');
     const wordDisplayElem = document.getElementById('word-display');
     const guessInput = document.getElementById('guess-input');
     const guessButton = document.getElementById('guess-button');
     const gameResults = document.getElementById('game-results');
+    const guessFeedback = document.getElementById('guess-feedback');
     const hintBoxes = document.querySelectorAll('.hint-box');
-    const playerID = generatePlayerID();
 
 
     const gameStateKey = `references-state-${gameId}`;
     const hintsStateKey = `references-hints-${gameId}`;
+    const feedbackStateKey = `references-feedback-${gameId}`;
 
     let remainingGuesses = 4;
     let revealedHintsData = {};
@@ -40,7 +30,8 @@ document.addEventListener('DOMContentLoaded', function () {
     // Get the word length from the masked word
     if (wordDisplayElem) {
         const maskedWord = wordDisplayElem.textContent.trim();
-        wordLength = maskedWord.replace(/\s/g, '').length;
+        // Letters are masked as _; spaces and punctuation are shown as written
+        wordLength = (maskedWord.match(/_/g) || []).length;
         
         // Hide the word display element as we'll use OTP input instead
         wordDisplayElem.style.display = 'none';
@@ -108,13 +99,52 @@ document.addEventListener('DOMContentLoaded', function () {
         if (hintsUsedElem) hintsUsedElem.textContent = usedHintsCount;
     }
 
+    // Per-letter feedback rows for puzzles in "letters" feedback mode
+    function loadFeedback() {
+        try {
+            const rows = JSON.parse(localStorage.getItem(feedbackStateKey) || '[]');
+            return Array.isArray(rows) ? rows : [];
+        } catch (e) {
+            console.error("Error parsing feedback from localStorage:", e);
+            return [];
+        }
+    }
+
+    function renderFeedback() {
+        if (!guessFeedback) return;
+        guessFeedback.textContent = '';
+        loadFeedback().forEach(row => {
+            const rowElem = document.createElement('div');
+            rowElem.className = 'feedback-row';
+            const letters = Array.from(row.guess || '');
+            (row.letters || []).forEach((result, i) => {
+                const cell = document.createElement('span');
+                cell.className = `feedback-letter feedback-${result}`;
+                cell.textContent = letters[i] || '';
+                rowElem.appendChild(cell);
+            });
+            guessFeedback.appendChild(rowElem);
+        });
+    }
+
+    function saveFeedback(guess, letters) {
+        const rows = loadFeedback();
+        rows.push({ guess: guess.replace(/[^\p{L}\p{N}]/gu, '').toUpperCase(), letters });
+        localStorage.setItem(feedbackStateKey, JSON.stringify(rows));
+        renderFeedback();
+    }
+
     function clearGameState() {
         localStorage.removeItem(gameStateKey);
         localStorage.removeItem(hintsStateKey);
+        localStorage.removeItem(feedbackStateKey);
         console.log(`Cleared game state for game ID: ${gameId}`);
     }
 
-    loadGameState();
+    if (!preview) {
+        loadGameState();
+        renderFeedback();
+    }
 
     function trackGameEvent(type, value, correct = false) {
         const eventsKey = `references-events-${gameId}`;
@@ -133,8 +163,16 @@ document.addEventListener('DOMContentLoaded', function () {
         localStorage.setItem(eventsKey, JSON.stringify(events));
     }
 
+    function resultURL(path) {
+        return `${path}?gameId=${encodeURIComponent(gameId)}`;
+    }
+
     // This function handles the guess submission
     function handleGuessSubmission(guess) {
+        if (preview) {
+            gameResults.textContent = 'Preview only: guesses are not submitted.';
+            return;
+        }
         if (remainingGuesses <= 0) return;
         
         if (!guess) {
@@ -153,38 +191,51 @@ document.addEventListener('DOMContentLoaded', function () {
         });
         
         gameResults.textContent = 'Checking...';
+        let keepGuess = false;
 
         fetch('/guess', {
             method: 'POST',
             headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
-            body: `guess=${encodeURIComponent(guess)}&playerID=${encodeURIComponent(generatePlayerID())}`
+            body: `guess=${encodeURIComponent(guess)}&gameId=${encodeURIComponent(gameId)}`
         })
         .then(response => {
-            if (!response.ok) return response.json().then(errData => { throw new Error(errData.error || `HTTP error ${response.status}`); });
+            if (!response.ok) return response.json().then(errData => {
+                const err = new Error(errData.error || `HTTP error ${response.status}`);
+                err.code = errData.code;
+                throw err;
+            });
             return response.json();
         })
         .then(data => {
             trackGameEvent('guess', guess, data.correct);
             gameResults.textContent = '';
 
+            remainingGuesses = data.remainingGuesses;
             saveGameState(data.maskedWord);
+            if (Array.isArray(data.letters)) {
+                saveFeedback(guess, data.letters);
+            }
 
             if (data.correct) {
-                const guessesTaken = 4 - remainingGuesses + 1;
+                const guessesTaken = 4 - remainingGuesses;
                 const finalHintsCount = Object.keys(revealedHintsData).length;
                 console.log(`Redirecting to success. Guesses: ${guessesTaken}, Hints: ${finalHintsCount}`);
-                window.location.href = `/success?word=${encodeURIComponent(data.word)}&guesses=${guessesTaken}&hints=${finalHintsCount}&gameId=${encodeURIComponent(gameId)}`;
+                window.location.href = resultURL('/success');
                 return;
             }
 
-            remainingGuesses--;
-            saveGameState(data.maskedWord);
-
             if (remainingGuesses <= 0) {
                 const finalHintsCount = Object.keys(revealedHintsData).length;
                 const guessesTaken = 4;
                 console.log(`Redirecting to maybe-tomorrow. Guesses: ${guessesTaken}, Hints: ${finalHintsCount}`);
-                window.location.href = `/maybe-tomorrow?word=${encodeURIComponent(data.word)}&guesses=${guessesTaken}&hints=${finalHintsCount}&gameId=${encodeURIComponent(gameId)}`;
+                window.location.href = resultURL('/maybe-tomorrow');
+                return;
+            }
+
+            if (data.close) {
+                // Near misses are not counted, so leave the guess in place to fix.
+                keepGuess = true;
+                gameResults.textContent = "So close! Check your spelling — that one didn't count.";
                 return;
             }
 
@@ -192,6 +243,16 @@ document.addEventListener('DOMContentLoaded', function () {
         })
         .catch(error => {
             console.error('Guess Error:', error);
+            if (error.code === 'no_guesses_left') {
+                remainingGuesses = 0;
+                saveGameState();
+                window.location.href = resultURL('/maybe-tomorrow');
+                return;
+            }
+            if (error.code === 'already_solved') {
+                window.location.href = resultURL('/success');
+                return;
+            }
             gameResults.textContent = `Error: ${error.message || 'Could not process guess.'}`;
         })
         .finally(() => {
@@ -204,7 +265,9 @@ document.addEventListener('DOMContentLoaded', function () {
                 });
                 
                 // Clear and focus the first input in OTP mode or the main input otherwise
-                if (otpInputs.length > 0) {
+                if (keepGuess) {
+                    (otpInputs.length > 0 ? otpInputs[otpInputs.length - 1] : guessInput)?.focus();
+                } else if (otpInputs.length > 0) {
                     otpInputs.forEach(input => input.value = '');
                     otpInputs[0].focus();
                 } else if (guessInput) {
@@ -235,7 +298,7 @@ document.addEventListener('DOMContentLoaded', function () {
         const category = box.dataset.category;
 
         box.addEventListener('click', function () {
-            if (this.classList.contains('hint-revealed') || this.classList.contains('hint-loading')) {
+            if (preview || this.classList.contains('hint-revealed') || this.classList.contains('hint-loading')) {
                 return;
             }
 
@@ -248,12 +311,12 @@ document.addEventListener('DOMContentLoaded', function () {
             fetch('/hint', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
-                body: `category=${encodeURIComponent(categoryName)}&playerID=${encodeURIComponent(generatePlayerID())}`
+                body: `category=${encodeURIComponent(categoryName)}&gameId=${encodeURIComponent(gameId)}`
             })
             .then(response => {
-                if (!response.ok) {
-                    throw new Error(`HTTP error ${response.status}`);
-                }
+                if (!response.ok) return response.json().then(errData => {
+                    throw new Error(errData.error || `HTTP error ${response.status}`);
+                });
                 return response.json();
             })
             .then(data => {
@@ -268,9 +331,9 @@ document.addEventListener('DOMContentLoaded', function () {
                     emoji: data.emoji || ''
                 };
 
-                usedHintsCount = Object.keys(revealedHintsData).length;
-                if (hintsUsedElem) hintsUsedElem.textContent = usedHintsCount;
                 saveHintsState();
+                usedHintsCount = data.hintsUsed;
+                if (hintsUsedElem) hintsUsedElem.textContent = usedHintsCount;
             })
             .catch(error => {
                 console.error('Hint Error:', error);
@@ -297,7 +360,7 @@ document.addEventListener('DOMContentLoaded', function () {
         
         // Get word length from the masked word
         const maskedWord = wordDisplayElem.textContent.trim();
-        const wordLength = maskedWord.replace(/\s/g, '').length;
+        const wordLength = (maskedWord.match(/_/g) || []).length;
         
         // Create OTP container
         const otpContainer = document.createElement('div');
@@ -306,6 +369,7 @@ document.addEventListener('DOMContentLoaded', function () {
         otpContainer.style.flexDirection = 'row';
         otpContainer.style.justifyContent = 'center';
         otpContainer.style.gap = '8px';
+        otpContainer.style.flexWrap = 'wrap';
         otpContainer.style.width = '100%';
         otpContainer.style.margin = '25px 0';
         
@@ -315,9 +379,21 @@ document.addEventListener('DOMContentLoaded', function () {
         hiddenInput.id = 'guess-input'; // Keep the original ID for compatibility
         hiddenInput.name = 'guess';
         
-        // Create input boxes
+        // Create input boxes for letters and fixed markers for separators
         const inputRefs = [];
-        for (let i = 0; i < wordLength; i++) {
+        for (const ch of Array.from(maskedWord)) {
+            if (ch !== '_') {
+                const separator = document.createElement('span');
+                separator.className = 'otp-separator';
+                separator.textContent = ch;
+                separator.style.display = 'flex';
+                separator.style.alignItems = 'center';
+                separator.style.fontSize = '1.5rem';
+                separator.style.minWidth = ch === ' ' ? '12px' : '0';
+                otpContainer.appendChild(separator);
+                continue;
+            }
+            const i = inputRefs.length;
             const inputBox = document.createElement('input');
             inputBox.type = 'text';
             inputBox.maxLength = 1;
@@ -418,7 +494,7 @@ document.addEventListener('DOMContentLoaded', function () {
             // Handle paste event
             input.addEventListener('paste', function(e) {
                 e.preventDefault();
-                const pasteData = e.clipboardData.getData('text').replace(/\s/g, '').slice(0, wordLength);
+                const pasteData = e.clipboardData.getData('text').replace(/[^\p{L}\p{N}]/gu, '').slice(0, wordLength);
                 
                 if (pasteData) {
                     // Fill as many boxes as we have characters